@@ -1,11 +1,14 @@
 package internal
 
 import (
+	"context"
 	"errors"
 	"time"
 
 	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+	"github.com/AlexxIT/SmartScaleConnect/pkg/fitbit"
 	"github.com/AlexxIT/SmartScaleConnect/pkg/garmin"
+	"github.com/AlexxIT/SmartScaleConnect/pkg/gfit"
 	"github.com/AlexxIT/SmartScaleConnect/pkg/picooc"
 	"github.com/AlexxIT/SmartScaleConnect/pkg/tanita"
 	"github.com/AlexxIT/SmartScaleConnect/pkg/xiaomi"
@@ -13,6 +16,8 @@ import (
 )
 
 const (
+	AccFitbitAPI  = "fitbit-api"
+	AccGFit       = "gfit"
 	AccGarmin     = "garmin"
 	AccMiFitness  = "mifitness"
 	AccPicooc     = "picooc"
@@ -22,35 +27,97 @@ const (
 	AccZeppXiaomi = "zepp/xiaomi"
 )
 
-var accounts map[string]core.Account
-var cacheTS time.Time
+// legacyTokenTTL is the cache lifetime for an account whose token carries no
+// known expiry (core.AccountWithExpiry not implemented) - the same 23h
+// hard timer the whole cache used to run on.
+const legacyTokenTTL = 23 * time.Hour
 
-func GetAccount(fields []string) (core.Account, error) {
-	// Clean accounts every 23 hours, because there is no logic for token expiration.
-	if now := time.Now(); now.After(cacheTS) {
-		accounts = map[string]core.Account{}
-		cacheTS = now.Add(23 * time.Hour)
+// expirySkew evicts a cache entry this long before its token's real expiry,
+// so a request doesn't race a token that's valid when checked but expired
+// by the time it reaches the provider.
+const expirySkew = 60 * time.Second
+
+// now is overridden in tests to drive the cache with a mock clock.
+var now = time.Now
+
+type cacheEntry struct {
+	account core.Account
+	expiry  time.Time
+}
+
+var accounts map[string]cacheEntry
+
+func GetAccount(ctx context.Context, fields []string) (core.Account, error) {
+	if accounts == nil {
+		accounts = map[string]cacheEntry{}
 	}
 
 	key := fields[0] + ":" + fields[1]
-	if account, ok := accounts[key]; ok {
-		return account, nil
+
+	if entry, ok := accounts[key]; ok {
+		if now().Before(entry.expiry) {
+			return entry.account, nil
+		}
+
+		if refreshed, err := refreshAccount(ctx, key, entry.account); err == nil {
+			accounts[key] = refreshed
+			return refreshed.account, nil
+		}
+
+		delete(accounts, key)
 	}
 
-	account, err := getAccount(fields, key)
+	account, err := getAccount(ctx, fields, key)
 	if err != nil {
 		return nil, err
 	}
 
-	accounts[key] = account
+	accounts[key] = newCacheEntry(account)
 
 	return account, nil
 }
 
-func getAccount(fields []string, key string) (core.Account, error) {
+// refreshAccount renews account's token in place via its own Refresh
+// method, for providers whose stored token carries a refresh token, instead
+// of discarding the session and re-running Login's username/password
+// exchange.
+func refreshAccount(ctx context.Context, key string, account core.Account) (cacheEntry, error) {
+	refresher, ok := account.(core.AccountWithRefresh)
+	if !ok {
+		return cacheEntry{}, errors.New("account does not support refresh")
+	}
+
+	if err := refresher.Refresh(ctx); err != nil {
+		return cacheEntry{}, err
+	}
+
+	if withToken, ok := account.(core.AccountWithToken); ok {
+		SaveToken(key, withToken.Token())
+	}
+
+	return newCacheEntry(account), nil
+}
+
+func newCacheEntry(account core.Account) cacheEntry {
+	expiry := now().Add(legacyTokenTTL)
+
+	if withExpiry, ok := account.(core.AccountWithExpiry); ok {
+		if e := withExpiry.TokenExpiry(); !e.IsZero() {
+			expiry = e.Add(-expirySkew)
+		}
+	}
+
+	return cacheEntry{account: account, expiry: expiry}
+}
+
+func getAccount(ctx context.Context, fields []string, key string) (core.Account, error) {
 	var acc core.Account
 
 	switch fields[0] {
+	case AccFitbitAPI:
+		acc = fitbit.NewClient("", "")
+	case AccGFit:
+		acc = gfit.NewClient("", "")
 	case AccGarmin:
 		acc = garmin.NewClient()
 	case AccPicooc:
@@ -69,13 +136,13 @@ func getAccount(fields []string, key string) (core.Account, error) {
 
 	if acc, ok := acc.(core.AccountWithToken); ok {
 		if token := LoadToken(key); token != "" {
-			if err := acc.LoginWithToken(token); err == nil {
+			if err := acc.LoginWithToken(ctx, token); err == nil {
 				return acc, nil
 			}
 		}
 	}
 
-	if err := acc.Login(fields[1], fields[2]); err != nil {
+	if err := acc.Login(ctx, fields[1], fields[2]); err != nil {
 		return nil, err
 	}
 
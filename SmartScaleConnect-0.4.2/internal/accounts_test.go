@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAccount is a minimal core.Account that also opts into
+// AccountWithToken/AccountWithExpiry/AccountWithRefresh, so the cache logic
+// in GetAccount/refreshAccount/newCacheEntry can be driven without a real
+// provider or network access.
+type fakeAccount struct {
+	expiresAt    time.Time
+	refreshErr   error
+	loginCalls   int
+	refreshCalls int
+}
+
+func (a *fakeAccount) Login(_ context.Context, _, _ string) error {
+	a.loginCalls++
+	return nil
+}
+
+func (a *fakeAccount) GetAllWeights(_ context.Context) ([]*core.Weight, error) {
+	return nil, nil
+}
+
+func (a *fakeAccount) LoginWithToken(_ context.Context, _ string) error {
+	return nil
+}
+
+func (a *fakeAccount) Token() string {
+	return "fake-token"
+}
+
+func (a *fakeAccount) TokenExpiry() time.Time {
+	return a.expiresAt
+}
+
+func (a *fakeAccount) Refresh(_ context.Context) error {
+	a.refreshCalls++
+	if a.refreshErr != nil {
+		return a.refreshErr
+	}
+	a.expiresAt = now().Add(time.Hour)
+	return nil
+}
+
+func withMockClock(t *testing.T) (advance func(d time.Duration)) {
+	t.Helper()
+
+	realNow, realAccounts, realTokens := now, accounts, tokens
+	mock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return mock }
+	accounts = map[string]cacheEntry{}
+	tokens = map[string]string{}
+
+	t.Cleanup(func() {
+		now = realNow
+		accounts = realAccounts
+		tokens = realTokens
+	})
+
+	return func(d time.Duration) { mock = mock.Add(d) }
+}
+
+func TestNewCacheEntry_WithExpiry(t *testing.T) {
+	withMockClock(t)
+
+	acc := &fakeAccount{expiresAt: now().Add(10 * time.Minute)}
+	entry := newCacheEntry(acc)
+
+	require.Equal(t, now().Add(10*time.Minute-expirySkew), entry.expiry)
+}
+
+func TestNewCacheEntry_WithoutExpiry(t *testing.T) {
+	withMockClock(t)
+
+	// no TokenExpiry set (zero value), so the legacy TTL applies
+	acc := &fakeAccount{}
+	entry := newCacheEntry(acc)
+
+	require.Equal(t, now().Add(legacyTokenTTL), entry.expiry)
+}
+
+func TestRefreshAccount_Success(t *testing.T) {
+	withMockClock(t)
+	t.Chdir(t.TempDir()) // refreshAccount persists the rotated token via SaveToken
+
+	acc := &fakeAccount{expiresAt: now().Add(time.Minute)}
+	entry, err := refreshAccount(context.Background(), "fitbit-api:alex", acc)
+
+	require.NoError(t, err)
+	require.Equal(t, 1, acc.refreshCalls)
+	require.Equal(t, 0, acc.loginCalls)
+	require.Equal(t, now().Add(time.Hour-expirySkew), entry.expiry)
+	require.Equal(t, "fake-token", LoadToken("fitbit-api:alex"))
+}
+
+func TestRefreshAccount_PropagatesError(t *testing.T) {
+	withMockClock(t)
+
+	acc := &fakeAccount{refreshErr: errors.New("token revoked")}
+	_, err := refreshAccount(context.Background(), "fitbit-api:alex", acc)
+
+	require.Error(t, err)
+	require.Equal(t, 1, acc.refreshCalls)
+}
+
+func TestGetAccount_ReturnsCachedAccountUntilExpiry(t *testing.T) {
+	withMockClock(t)
+
+	acc := &fakeAccount{}
+	accounts["faketype:user1"] = cacheEntry{account: acc, expiry: now().Add(time.Hour)}
+
+	got, err := GetAccount(context.Background(), []string{"faketype", "user1"})
+
+	require.NoError(t, err)
+	require.Same(t, acc, got)
+	require.Equal(t, 0, acc.loginCalls)
+	require.Equal(t, 0, acc.refreshCalls)
+}
+
+func TestGetAccount_RefreshesInsteadOfReLoginWhenTokenIsStale(t *testing.T) {
+	advance := withMockClock(t)
+	t.Chdir(t.TempDir()) // the refresh path persists the rotated token via SaveToken
+
+	acc := &fakeAccount{expiresAt: now().Add(time.Minute)}
+	accounts["faketype:user1"] = cacheEntry{account: acc, expiry: now().Add(-time.Second)}
+
+	advance(time.Second) // now() has passed the cached entry's expiry
+
+	got, err := GetAccount(context.Background(), []string{"faketype", "user1"})
+
+	require.NoError(t, err)
+	require.Same(t, acc, got)
+	require.Equal(t, 1, acc.refreshCalls)
+	require.Equal(t, 0, acc.loginCalls)
+
+	cached, ok := accounts["faketype:user1"]
+	require.True(t, ok)
+	require.Equal(t, now().Add(time.Hour-expirySkew), cached.expiry)
+}
@@ -3,6 +3,7 @@ package internal
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
@@ -10,21 +11,69 @@ import (
 	"github.com/expr-lang/expr/vm"
 )
 
+// segmentalFloatFields are the float32 leaves reachable under
+// Segmental.<Region>.<Field>, e.g. "Segmental.LeftLeg.MuscleMass".
+var segmentalFloatFields = map[string]bool{
+	"FatMass":    true,
+	"MuscleMass": true,
+}
+
+// segmentalIntFields are the int leaves reachable under
+// Segmental.<Region>.<Field>, e.g. "Segmental.LeftLeg.MuscleRank".
+var segmentalIntFields = map[string]bool{
+	"FatRank":    true,
+	"MuscleRank": true,
+}
+
+// assessmentFields are the leaves reachable under Assessment.<Field>, e.g.
+// "Assessment.SpO2". Keypoints isn't addressable here - it's read by
+// expressions, not assigned by them.
+var assessmentFields = map[string]bool{
+	"BloodPressureSys": true,
+	"BloodPressureDia": true,
+	"SpO2":             true,
+	"BalanceAngle":     true,
+	"AgilityMs":        true,
+	"AerobicKcal":      true,
+}
+
 func Expr(config map[string]string, weights []*core.Weight) error {
 	programs := map[string]*vm.Program{}
 
 	for key, input := range config {
 		var opt expr.Option
 
-		switch key {
-		case "Date":
+		switch {
+		case key == "Date":
 			opt = expr.AsAny()
-		case "Weight", "BMI", "BodyFat", "BodyWater", "BoneMass", "MuscleMass", "ProteinMass", "Height", "SkeletalMuscleMass":
+		case key == "Weight", key == "BMI", key == "BodyFat", key == "BodyWater", key == "BoneMass",
+			key == "MuscleMass", key == "ProteinMass", key == "Height", key == "SkeletalMuscleMass":
 			opt = expr.AsFloat64()
-		case "MetabolicAge", "PhysiqueRating", "VisceralFat", "BasalMetabolism", "BodyScore", "HeartRate":
+		case key == "MetabolicAge", key == "PhysiqueRating", key == "VisceralFat", key == "BasalMetabolism",
+			key == "BodyScore", key == "HeartRate":
 			opt = expr.AsInt()
-		case "User", "Source":
+		case key == "User", key == "Source":
 			opt = expr.AsKind(reflect.String)
+		case key == "Segmental.SkeletalMuscleIndex":
+			opt = expr.AsFloat64()
+		case strings.HasPrefix(key, "Segmental."):
+			parts := strings.Split(key, ".")
+			switch {
+			case len(parts) != 3:
+				return fmt.Errorf("invalid segmental field: %s", key)
+			case segmentalFloatFields[parts[2]]:
+				opt = expr.AsFloat64()
+			case segmentalIntFields[parts[2]]:
+				opt = expr.AsInt()
+			default:
+				return fmt.Errorf("invalid segmental field: %s", key)
+			}
+		case strings.HasPrefix(key, "Assessment."):
+			parts := strings.Split(key, ".")
+			if len(parts) != 2 || !assessmentFields[parts[1]] {
+				return fmt.Errorf("invalid assessment field: %s", key)
+			}
+			opt = expr.AsAny()
 		}
 
 		program, err := expr.Compile(input, opt)
@@ -83,9 +132,136 @@ func Expr(config map[string]string, weights []*core.Weight) error {
 				weight.User = v.(string)
 			case "Source":
 				weight.Source = v.(string)
+			default:
+				switch {
+				case strings.HasPrefix(key, "Segmental."):
+					if weight.Segmental == nil {
+						weight.Segmental = &core.Segmental{}
+					}
+					setSegmentalField(weight.Segmental, key, v)
+				case strings.HasPrefix(key, "Assessment."):
+					if weight.Assessment == nil {
+						weight.Assessment = &core.Assessment{}
+					}
+					if err = setAssessmentField(weight.Assessment, key, v); err != nil {
+						return err
+					}
+				}
 			}
 		}
 	}
 
 	return nil
 }
+
+// setSegmentalField assigns v to the Segmental.<Region>.<Field> leaf (or the
+// top-level Segmental.SkeletalMuscleIndex) named by key, e.g.
+// "Segmental.LeftLeg.MuscleMass".
+func setSegmentalField(seg *core.Segmental, key string, v any) {
+	parts := strings.Split(key, ".")
+
+	if len(parts) == 2 && parts[1] == "SkeletalMuscleIndex" {
+		seg.SkeletalMuscleIndex = float32(v.(float64))
+		return
+	}
+	if len(parts) != 3 {
+		return
+	}
+
+	var region *core.LimbComposition
+	switch parts[1] {
+	case "LeftArm":
+		region = &seg.LeftArm
+	case "RightArm":
+		region = &seg.RightArm
+	case "LeftLeg":
+		region = &seg.LeftLeg
+	case "RightLeg":
+		region = &seg.RightLeg
+	case "Trunk":
+		region = &seg.Trunk
+	default:
+		return
+	}
+
+	switch parts[2] {
+	case "FatMass":
+		region.FatMass = float32(v.(float64))
+	case "FatRank":
+		region.FatRank = v.(int)
+	case "MuscleMass":
+		region.MuscleMass = float32(v.(float64))
+	case "MuscleRank":
+		region.MuscleRank = v.(int)
+	}
+}
+
+// setAssessmentField assigns v to the Assessment.<Field> leaf named by key,
+// e.g. "Assessment.SpO2". Assessment mixes int and float32 fields behind a
+// single AsAny() compile option, so - unlike the tables above - v's
+// concrete type is coerced by reflection on the destination field's kind
+// rather than asserted to one fixed type.
+func setAssessmentField(a *core.Assessment, key string, v any) error {
+	parts := strings.Split(key, ".")
+	if len(parts) != 2 {
+		return nil
+	}
+
+	field := reflect.ValueOf(a).Elem().FieldByName(parts[1])
+	if !field.IsValid() || !field.CanSet() {
+		return fmt.Errorf("invalid assessment field: %s", key)
+	}
+
+	switch field.Kind() {
+	case reflect.Int:
+		n, ok := asInt(v)
+		if !ok {
+			return fmt.Errorf("invalid assessment value for %s: %v", key, v)
+		}
+		field.SetInt(int64(n))
+	case reflect.Float32:
+		f, ok := asFloat(v)
+		if !ok {
+			return fmt.Errorf("invalid assessment value for %s: %v", key, v)
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported assessment field kind: %s", field.Kind())
+	}
+
+	return nil
+}
+
+// asInt coerces expr's AsAny() result to an int, accepting the numeric
+// kinds expr-lang commonly produces.
+func asInt(v any) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	case float32:
+		return int(n), true
+	default:
+		return 0, false
+	}
+}
+
+// asFloat coerces expr's AsAny() result to a float64, accepting the numeric
+// kinds expr-lang commonly produces.
+func asFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
@@ -1,41 +1,110 @@
 package internal
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"os"
 	"strings"
 )
 
+const tokenFile = "scaleconnect.json"
+
 var tokens = map[string]string{}
 
+// tokenEnvelope is the on-disk shape once a token store has been
+// encrypted. V discriminates it from the legacy flat {"key":"token"} file:
+// a legacy file unmarshals into this struct with V left at its zero value.
+type tokenEnvelope struct {
+	V     int    `json:"v"`
+	Nonce string `json:"nonce"` // base64
+	CT    string `json:"ct"`    // base64
+}
+
 func LoadToken(key string) string {
-	key = replaceKey(key)
+	ensureLoaded()
+	return tokens[replaceKey(key)]
+}
 
-	if len(tokens) == 0 {
-		f, err := os.Open("scaleconnect.json")
-		if err != nil {
-			return ""
-		}
-		defer f.Close()
+func SaveToken(key string, value string) {
+	ensureLoaded()
+
+	tokens[replaceKey(key)] = value
+
+	persistTokens()
+}
+
+// LogoutToken removes a single provider:user entry, e.g. for a
+// `--logout fitbit-api:alex@gmail.com` CLI flag.
+func LogoutToken(key string) {
+	ensureLoaded()
+
+	delete(tokens, replaceKey(key))
+
+	persistTokens()
+}
+
+// ensureLoaded lazily reads tokenFile into tokens on first use, decrypting
+// its versioned envelope or, for a legacy plaintext file, loading it as-is -
+// the next persistTokens call re-writes it encrypted.
+func ensureLoaded() {
+	if len(tokens) != 0 {
+		return
+	}
+
+	data, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return
+	}
 
-		_ = json.NewDecoder(f).Decode(&tokens)
+	var env tokenEnvelope
+	if err = json.Unmarshal(data, &env); err == nil && env.V != 0 {
+		if data, err = decryptTokens(env); err != nil {
+			return
+		}
 	}
 
-	return tokens[key]
+	_ = json.Unmarshal(data, &tokens)
 }
 
-func SaveToken(key string, value string) {
-	key = replaceKey(key)
+// persistTokens encrypts the current tokens map and writes it via a temp
+// file + os.Rename, so a crash mid-write can't leave scaleconnect.json
+// truncated and wipe every provider's credentials at once.
+func persistTokens() {
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return
+	}
+
+	env, err := encryptTokens(data)
+	if err != nil {
+		return
+	}
 
-	tokens[key] = value
+	data, err = json.Marshal(env)
+	if err != nil {
+		return
+	}
 
-	f, err := os.Create("scaleconnect.json")
+	tmp := tokenFile + ".tmp"
+
+	f, err := os.Create(tmp)
 	if err != nil {
 		return
 	}
-	defer f.Close()
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
 
-	_ = json.NewEncoder(f).Encode(&tokens)
+	_ = os.Rename(tmp, tokenFile)
 }
 
 func replaceKey(key string) string {
@@ -45,3 +114,96 @@ func replaceKey(key string) string {
 	}
 	return key
 }
+
+// storeKey derives the AES-256 key used to encrypt scaleconnect.json, from
+// SCALECONNECT_KEY when set, or otherwise from a machine-bound value (the
+// executable path plus hostname) so an install doesn't need a passphrase to
+// work out of the box, at the cost of the store only traveling safely with
+// the machine it was written on.
+func storeKey() []byte {
+	passphrase := os.Getenv("SCALECONNECT_KEY")
+	if passphrase == "" {
+		exe, _ := os.Executable()
+		host, _ := os.Hostname()
+		passphrase = exe + "|" + host
+	}
+
+	prk := hkdfExtract(nil, []byte(passphrase))
+	return hkdfExpand(prk, []byte("scaleconnect-token-store"), 32)
+}
+
+func encryptTokens(plaintext []byte) (tokenEnvelope, error) {
+	block, err := aes.NewCipher(storeKey())
+	if err != nil {
+		return tokenEnvelope{}, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return tokenEnvelope{}, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return tokenEnvelope{}, err
+	}
+
+	ct := gcm.Seal(nil, nonce, plaintext, nil)
+
+	return tokenEnvelope{
+		V:     1,
+		Nonce: base64.StdEncoding.EncodeToString(nonce),
+		CT:    base64.StdEncoding.EncodeToString(ct),
+	}, nil
+}
+
+func decryptTokens(env tokenEnvelope) ([]byte, error) {
+	nonce, err := base64.StdEncoding.DecodeString(env.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	ct, err := base64.StdEncoding.DecodeString(env.CT)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(storeKey())
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return gcm.Open(nil, nonce, ct, nil)
+}
+
+// hkdfExtract and hkdfExpand are RFC 5869's HKDF-SHA256, hand-rolled to
+// avoid pulling in golang.org/x/crypto for two dozen lines of HMAC calls.
+func hkdfExtract(salt, ikm []byte) []byte {
+	if len(salt) == 0 {
+		salt = make([]byte, sha256.Size)
+	}
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(ikm)
+	return mac.Sum(nil)
+}
+
+func hkdfExpand(prk, info []byte, length int) []byte {
+	var (
+		t   []byte
+		okm []byte
+	)
+	for i := byte(1); len(okm) < length; i++ {
+		mac := hmac.New(sha256.New, prk)
+		mac.Write(t)
+		mac.Write(info)
+		mac.Write([]byte{i})
+		t = mac.Sum(nil)
+		okm = append(okm, t...)
+	}
+	return okm[:length]
+}
@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+	"github.com/AlexxIT/SmartScaleConnect/pkg/csv"
+	"github.com/AlexxIT/SmartScaleConnect/pkg/fitbit"
+	"github.com/AlexxIT/SmartScaleConnect/pkg/webhook"
+)
+
+// webhookAddr is the shared listen address for every configured endpoint;
+// individual entries only choose the path they're mounted at.
+const webhookAddr = ":8099"
+
+// WebhookEndpoint is one entry of the config's top-level `webhook:` list.
+type WebhookEndpoint struct {
+	Path    string   `yaml:"path"`    // e.g. "/csv", "/json", "/fitbit"
+	Format  string   `yaml:"format"`  // "csv", "json" or "fitbit"
+	Secret  string   `yaml:"secret"`  // HMAC key
+	Algo    string   `yaml:"algo"`    // "fitbit" or "sha256" (default "sha256")
+	From    string   `yaml:"from"`    // account to resolve notifications through, e.g. "fitbit-api <clientID> <token>" - same syntax as a sync's `from` (only "fitbit" format; falls back to decodeFitbitNotification when empty)
+	Targets []string `yaml:"targets"` // forwarded to SetWeights, same syntax as a sync's `to`
+}
+
+var webhookServers sync.Map // addr string -> struct{}{} marker, so repeated process() calls don't redial the same port
+
+// StartWebhookServer mounts every configured endpoint on webhookAddr and
+// starts listening, unless a server is already running from a previous
+// process() call (e.g. in --repeat/--interactive mode). ctx is only used to
+// log in any endpoint's From account before the server starts; it isn't
+// kept past that.
+func StartWebhookServer(ctx context.Context, endpoints []WebhookEndpoint) error {
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	if _, loaded := webhookServers.LoadOrStore(webhookAddr, struct{}{}); loaded {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	for _, ep := range endpoints {
+		sub, err := newSubscriber(ctx, ep)
+		if err != nil {
+			webhookServers.Delete(webhookAddr)
+			return err
+		}
+		mux.Handle(ep.Path, sub)
+	}
+
+	server := &http.Server{Addr: webhookAddr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("webhook: server error: %v\n", err)
+		}
+	}()
+
+	return nil
+}
+
+func newSubscriber(ctx context.Context, ep WebhookEndpoint) (*webhook.Subscriber, error) {
+	var verifier webhook.Verifier
+	switch ep.Algo {
+	case "", "sha256":
+		verifier = webhook.SHA256Verifier{Secret: ep.Secret}
+	case "fitbit":
+		verifier = webhook.FitbitVerifier{ClientSecret: ep.Secret}
+	default:
+		return nil, fmt.Errorf("webhook: unsupported algo: %s", ep.Algo)
+	}
+
+	decode, err := decoderFor(ctx, ep)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhook.Subscriber{
+		Verifier: verifier,
+		Decode:   decode,
+		OnWeights: func(ctx context.Context, weights []*core.Weight) error {
+			for _, target := range ep.Targets {
+				if err := SetWeights(ctx, target, weights); err != nil {
+					log.Printf("webhook %s: write to %q failed: %v\n", ep.Path, target, err)
+				}
+			}
+			return nil
+		},
+		// Fitbit's notifications carry no weight data themselves (see
+		// fitbit.Client.HandleNotification): Decode re-fetches each changed
+		// date from the Web API before this handler can respond. Queuing
+		// that pull instead of awaiting it inline keeps Fitbit's webhook
+		// delivery - which treats a slow or failing callback as reason to
+		// retry or eventually unsubscribe - from tripping over our own
+		// upstream latency.
+		Async: ep.Format == "fitbit",
+	}, nil
+}
+
+// decoderFor resolves ep's Decode func. "fitbit" with a From account wires
+// the real subscription flow (core.Subscribable.HandleNotification); without
+// one it falls back to decodeFitbitNotification's value-carrying payload,
+// for configs that post pre-parsed records instead of a real Fitbit
+// subscription callback.
+func decoderFor(ctx context.Context, ep WebhookEndpoint) (func(ctx context.Context, body []byte) ([]*core.Weight, error), error) {
+	switch ep.Format {
+	case "csv":
+		return func(_ context.Context, body []byte) ([]*core.Weight, error) {
+			return csv.Read(bytes.NewReader(body))
+		}, nil
+
+	case "json":
+		return func(_ context.Context, body []byte) ([]*core.Weight, error) {
+			var weights []*core.Weight
+			err := json.Unmarshal(body, &weights)
+			return weights, err
+		}, nil
+
+	case "fitbit":
+		if ep.From == "" {
+			return func(_ context.Context, body []byte) ([]*core.Weight, error) {
+				return decodeFitbitNotification(body)
+			}, nil
+		}
+
+		acc, err := GetAccount(ctx, strings.Fields(ep.From))
+		if err != nil {
+			return nil, err
+		}
+		sub, ok := acc.(core.Subscribable)
+		if !ok {
+			return nil, fmt.Errorf("webhook: %s: account does not support subscriptions", ep.Path)
+		}
+		return sub.HandleNotification, nil
+
+	default:
+		return nil, fmt.Errorf("webhook: unsupported format: %s", ep.Format)
+	}
+}
+
+// decodeFitbitNotification parses a Fitbit weight-log style payload, i.e.
+// the same per-record shape pkg/fitbit.Read already understands from the
+// Takeout export, not Fitbit's real (value-less) subscription notification.
+func decodeFitbitNotification(body []byte) ([]*core.Weight, error) {
+	var records []struct {
+		Weight float32 `json:"weight"`
+		Bmi    float32 `json:"bmi"`
+		Fat    float32 `json:"fat"`
+		Date   string  `json:"date"` // "2024-01-15"
+		Time   string  `json:"time"` // "07:30:00"
+		Source string  `json:"source"`
+	}
+	if err := json.Unmarshal(body, &records); err != nil {
+		return nil, err
+	}
+
+	weights := make([]*core.Weight, 0, len(records))
+	for _, v := range records {
+		date, _ := time.Parse("2006-01-02 15:04:05", v.Date+" "+v.Time)
+		weights = append(weights, &core.Weight{
+			Date:    date,
+			Weight:  v.Weight * fitbit.LBS2KG,
+			BMI:     v.Bmi,
+			BodyFat: v.Fat,
+			Source:  v.Source,
+		})
+	}
+
+	return weights, nil
+}
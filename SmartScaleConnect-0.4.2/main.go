@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -22,6 +23,7 @@ const usage = `Usage of scaleconnect:
   -c, --config       Path to config file
   -i, --interactive  Keep STDIN open
   -r, --repeat       Run config every N time (format: 2h45m)
+      --logout       Remove one saved token, e.g. "fitbit-api:alex@gmail.com"
 `
 
 func main() {
@@ -29,6 +31,7 @@ func main() {
 		config      string
 		repeat      string
 		interactive bool
+		logout      string
 	)
 
 	flag.Usage = func() { fmt.Print(usage) }
@@ -38,10 +41,19 @@ func main() {
 	flag.StringVar(&repeat, "r", "", "")
 	flag.BoolVar(&interactive, "interactive", false, "")
 	flag.BoolVar(&interactive, "i", false, "")
+	flag.StringVar(&logout, "logout", "", "")
 	flag.Parse()
 
 	log.Printf("scaleconnect version %s\n", Version)
 
+	if logout != "" {
+		internal.LogoutToken(logout)
+		os.Exit(0)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	data, err := readConfig(config)
 
 	// run config once
@@ -50,7 +62,7 @@ func main() {
 			log.Fatal(err)
 		}
 
-		if err = process(data); err != nil {
+		if err = process(ctx, data); err != nil {
 			log.Fatal(err)
 		}
 
@@ -92,15 +104,14 @@ func main() {
 
 	go func() {
 		for data = range configs {
-			if err = process(data); err != nil {
+			if err = process(ctx, data); err != nil {
 				log.Fatal(err)
 			}
 		}
 	}()
 
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-	fmt.Printf("exit with signal: %s\n", <-sigs)
+	<-ctx.Done()
+	fmt.Println("exit with signal")
 }
 
 const configName = "scaleconnect.yaml"
@@ -137,14 +148,39 @@ func readConfig(name string) ([]byte, error) {
 	return data, os.Chdir(path)
 }
 
-func process(data []byte) error {
-	var syncs map[string]struct {
+func process(ctx context.Context, data []byte) error {
+	var raw map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if node, ok := raw["webhook"]; ok {
+		var endpoints []internal.WebhookEndpoint
+		if err := node.Decode(&endpoints); err != nil {
+			return err
+		}
+		if err := internal.StartWebhookServer(ctx, endpoints); err != nil {
+			return err
+		}
+		delete(raw, "webhook")
+	}
+
+	syncs := map[string]struct {
 		From any               `yaml:"from"`
 		To   string            `yaml:"to"`
 		Expr map[string]string `yaml:"expr"`
-	}
-	if err := yaml.Unmarshal(data, &syncs); err != nil {
-		return err
+	}{}
+
+	for name, node := range raw {
+		var v struct {
+			From any               `yaml:"from"`
+			To   string            `yaml:"to"`
+			Expr map[string]string `yaml:"expr"`
+		}
+		if err := node.Decode(&v); err != nil {
+			return err
+		}
+		syncs[name] = v
 	}
 
 	for name, v := range syncs {
@@ -152,7 +188,7 @@ func process(data []byte) error {
 			continue
 		}
 
-		weights, err := internal.GetWeights(v.From)
+		weights, err := internal.GetWeights(ctx, v.From)
 		if err != nil {
 			log.Printf("%s: load data error: %v\n", name, err)
 			continue
@@ -165,7 +201,7 @@ func process(data []byte) error {
 			}
 		}
 
-		if err = internal.SetWeights(v.To, weights); err != nil {
+		if err = internal.SetWeights(ctx, v.To, weights); err != nil {
 			log.Printf("%s: write data error: %v\n", name, err)
 			continue
 		}
@@ -1,22 +1,57 @@
 package core
 
+import (
+	"context"
+	"time"
+)
+
 type Account interface {
-	Login(username, password string) error
-	GetAllWeights() ([]*Weight, error)
+	Login(ctx context.Context, username, password string) error
+	GetAllWeights(ctx context.Context) ([]*Weight, error)
 }
 
 type AccountWithToken interface {
 	Account
-	LoginWithToken(token string) error
+	LoginWithToken(ctx context.Context, token string) error
 	Token() string
 }
 
 type AccountWithFilter interface {
-	GetFilterWeights(name string) ([]*Weight, error)
+	GetFilterWeights(ctx context.Context, name string) ([]*Weight, error)
+}
+
+// AccountWithExpiry is implemented by accounts whose current token carries
+// a known expiry (OAuth2's access_token lifetime), so a cache can evict
+// just that entry when it's about to expire instead of flushing everything
+// on a hard timer.
+type AccountWithExpiry interface {
+	TokenExpiry() time.Time
+}
+
+// AccountWithRefresh is implemented by accounts that can renew their
+// current token from a refresh token, without re-running Login's
+// username/password exchange.
+type AccountWithRefresh interface {
+	Refresh(ctx context.Context) error
 }
 
 type AccountWithAddWeights interface {
-	AddWeights(weights []*Weight) error
-	DeleteWeight(weight *Weight) error
+	AddWeights(ctx context.Context, weights []*Weight) error
+	DeleteWeight(ctx context.Context, weight *Weight) error
 	Equal(a, b *Weight) bool
 }
+
+// Subscribable is implemented by accounts that can register for a vendor's
+// push notifications (e.g. Fitbit's Subscription API) instead of being
+// polled for GetAllWeights on an interval.
+type Subscribable interface {
+	// Subscribe registers userTag (the vendor's subscriber/subscription ID)
+	// to receive notifications for this account.
+	Subscribe(ctx context.Context, userTag string) error
+
+	// HandleNotification turns one webhook callback's raw body into the
+	// weigh-ins it refers to. Most vendors' notifications carry no weight
+	// data themselves, so this typically re-fetches whatever the
+	// notification points at.
+	HandleNotification(ctx context.Context, payload []byte) ([]*Weight, error)
+}
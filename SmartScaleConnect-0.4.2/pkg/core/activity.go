@@ -0,0 +1,50 @@
+package core
+
+import "time"
+
+// Activity is a single workout/exercise session, as reported by the
+// step-counting/exercise side of a fitness tracker rather than its scale
+// integration.
+type Activity struct {
+	Start    time.Time     `json:"Start"`
+	Duration time.Duration `json:"Duration"`
+
+	Type     string  `json:"Type,omitempty"`     // e.g. "running", "cycling"
+	Distance float32 `json:"Distance,omitempty"` // meters
+	Calories int     `json:"Calories,omitempty"` // kcal
+
+	AvgHeartRate int     `json:"AvgHeartRate,omitempty"` // beats per minute
+	MaxHeartRate int     `json:"MaxHeartRate,omitempty"` // beats per minute
+	AvgSpeed     float32 `json:"AvgSpeed,omitempty"`     // m/s
+	AvgCadence   int     `json:"AvgCadence,omitempty"`   // steps or rpm per minute
+
+	Source string `json:"Source,omitempty"`
+}
+
+// Sleep is one sleep session, broken into the stages a tracker reports.
+type Sleep struct {
+	Start time.Time `json:"Start"`
+	End   time.Time `json:"End"`
+
+	Stages []SleepStage `json:"Stages,omitempty"`
+
+	Source string `json:"Source,omitempty"`
+}
+
+// SleepStage is one contiguous block of a single sleep stage within a
+// Sleep session, starting at Start and running until the next stage's
+// Start (or the session's End, for the last one).
+type SleepStage struct {
+	Start time.Time `json:"Start"`
+	Level string    `json:"Level"` // "awake", "light", "deep", "rem"
+}
+
+// HeartRateSample is one continuous (all-day) heart-rate/step reading, as
+// opposed to the per-workout summaries on Activity.
+type HeartRateSample struct {
+	Date      time.Time `json:"Date"`
+	HeartRate int       `json:"HeartRate,omitempty"` // beats per minute
+	Steps     int       `json:"Steps,omitempty"`
+
+	Source string `json:"Source,omitempty"`
+}
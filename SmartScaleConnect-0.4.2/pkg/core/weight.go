@@ -29,6 +29,12 @@ type Weight struct {
 	Height             float32 `json:"Height,omitempty"`             // cm
 	SkeletalMuscleMass float32 `json:"SkeletalMuscleMass,omitempty"` // kg
 
+	// 8-electrode scales (e.g. Xiaomi "Eight")
+	Segmental *Segmental `json:"Segmental,omitempty"`
+
+	// scales/apps that pair with a camera or BP cuff for a fitness test
+	Assessment *Assessment `json:"Assessment,omitempty"`
+
 	User   string `json:"User,omitempty"`
 	Source string `json:"Source,omitempty"`
 
@@ -39,6 +45,51 @@ type Weight struct {
 	//WHR float32 // Waist-to-Hip Ratio (WHR)
 }
 
+// LimbComposition is the fat/muscle breakdown for one limb or the trunk, as
+// reported by 8-electrode (two-foot, two-hand) body composition scales.
+type LimbComposition struct {
+	FatMass    float32 `json:"FatMass,omitempty"`    // kg
+	FatRank    int     `json:"FatRank,omitempty"`    // 1-3, low/normal/high
+	MuscleMass float32 `json:"MuscleMass,omitempty"` // kg
+	MuscleRank int     `json:"MuscleRank,omitempty"` // 1-3, low/normal/high
+}
+
+// Segmental is the per-region body composition breakdown reported by
+// 8-electrode scales (e.g. Xiaomi "Eight"), in addition to the whole-body
+// totals already on Weight.
+type Segmental struct {
+	LeftArm  LimbComposition `json:"LeftArm"`
+	RightArm LimbComposition `json:"RightArm"`
+	LeftLeg  LimbComposition `json:"LeftLeg"`
+	RightLeg LimbComposition `json:"RightLeg"`
+	Trunk    LimbComposition `json:"Trunk"`
+
+	SkeletalMuscleIndex float32 `json:"SkeletalMuscleIndex,omitempty"` // kg/m^2
+}
+
+// Assessment carries a fitness-test result from a scale or app that pairs
+// with a phone camera or Bluetooth BP cuff, alongside the weigh-in it was
+// taken with.
+type Assessment struct {
+	BloodPressureSys int     `json:"BloodPressureSys,omitempty"` // mmHg
+	BloodPressureDia int     `json:"BloodPressureDia,omitempty"` // mmHg
+	SpO2             int     `json:"SpO2,omitempty"`             // percent
+	BalanceAngle     float32 `json:"BalanceAngle,omitempty"`     // degrees
+	AgilityMs        int     `json:"AgilityMs,omitempty"`        // milliseconds
+	AerobicKcal      int     `json:"AerobicKcal,omitempty"`      // kcal
+
+	// Keypoints is the pose-estimation skeleton, keyed by joint name (e.g.
+	// "leftShoulder", "rightHip") when the source is a camera-based test.
+	Keypoints map[string]Keypoint `json:"Keypoints,omitempty"`
+}
+
+// Keypoint is a single pose-estimation joint: its position and confidence.
+type Keypoint struct {
+	X     float32 `json:"X"`
+	Y     float32 `json:"Y"`
+	Score float32 `json:"Score"`
+}
+
 func Equal(w1, w2 *Weight) bool {
 	return w1.Weight == w2.Weight &&
 		w1.BMI == w2.BMI &&
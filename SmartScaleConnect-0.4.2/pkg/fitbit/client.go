@@ -0,0 +1,436 @@
+package fitbit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+	"github.com/AlexxIT/SmartScaleConnect/pkg/httpx"
+	"github.com/AlexxIT/SmartScaleConnect/pkg/tokenstore"
+)
+
+// DefaultScope requests the two permissions GetAllWeights/PushWeights need:
+// reading/writing weight logs, and the profile's unit preference.
+const DefaultScope = "weight profile"
+
+// Client talks to the Fitbit Web API as an OAuth2 client (Authorization
+// Code + PKCE), treating Fitbit as both a weight source (GetAllWeights) and
+// destination (PushWeights) alongside the scale backends. Login/
+// LoginWithToken/Token follow the same pattern as garmin.Client, and
+// GetAllWeights already folds in body-fat logs and Fitbit's 1-month
+// windowing - this is the whole of the "Fitbit source" surface, so there's
+// nothing further to add here.
+type Client struct {
+	client *http.Client
+
+	clientID     string
+	clientSecret string // empty for a public (PKCE-only) client
+
+	accessToken  string
+	refreshToken string
+	expiresAt    time.Time
+
+	// Pounds reports weight/fat in lb instead of kg, matching the Fitbit
+	// account's own unit preference (there is no server-side conversion).
+	Pounds bool
+
+	onRefresh func(newToken string)
+
+	// logIDs maps a weigh-in's date (YYYY-MM-DD) to its Fitbit weight-log
+	// ID, so DeleteWeight knows what to delete.
+	logIDs map[string]string
+}
+
+func NewClient(clientID, clientSecret string) *Client {
+	return &Client{
+		client:       &http.Client{Timeout: time.Minute},
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		logIDs:       make(map[string]string),
+	}
+}
+
+// Login resumes a session from a refresh token obtained out-of-band via the
+// AuthorizeURL/Exchange authorization-code+PKCE flow (Fitbit's own consent
+// screen can't be scripted like the Xiaomi/Garmin logins). clientID is the
+// Fitbit app's client ID; refreshToken is pasted in once after completing
+// that flow, same as config-file integrations that can't open a browser.
+func (c *Client) Login(ctx context.Context, clientID, refreshToken string) error {
+	c.clientID = clientID
+	c.refreshToken = refreshToken
+	return c.Refresh(ctx)
+}
+
+// NewCodeVerifier generates a PKCE code_verifier for AuthorizeURL/Exchange.
+func NewCodeVerifier() string {
+	return core.RandString(64, 64)
+}
+
+// codeChallenge derives the S256 PKCE code_challenge from a code_verifier.
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthorizeURL builds the interactive authorization-code URL a user opens in
+// a browser to grant access. scope defaults to DefaultScope when empty.
+func AuthorizeURL(clientID, redirectURI, scope, state, codeVerifier string) string {
+	if scope == "" {
+		scope = DefaultScope
+	}
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {scope},
+		"state":                 {state},
+		"code_challenge":        {codeChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+	return "https://www.fitbit.com/oauth2/authorize?" + q.Encode()
+}
+
+// Exchange trades an authorization code for tokens, completing PKCE with the
+// code_verifier used to build AuthorizeURL.
+func (c *Client) Exchange(ctx context.Context, code, codeVerifier, redirectURI string) error {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {c.clientID},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {codeVerifier},
+	}
+	return c.postToken(ctx, form)
+}
+
+// Refresh exchanges the current refresh token for a new access token.
+func (c *Client) Refresh(ctx context.Context) error {
+	if c.refreshToken == "" {
+		return errors.New("fitbit: no refresh token")
+	}
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {c.refreshToken},
+	}
+	return c.postToken(ctx, form)
+}
+
+// OnRefresh registers fn to be called with the new Token() whenever Refresh
+// succeeds, so the caller can persist it.
+func (c *Client) OnRefresh(fn func(newToken string)) {
+	c.onRefresh = fn
+}
+
+// SetTokenStore wires store as c's persistence backend, namespaced under
+// user: built on OnRefresh, so every rotated token is saved immediately
+// instead of the caller having to serialize Token() by hand, the same way
+// garmin.Client.SetTokenStore does for its own provider.
+func (c *Client) SetTokenStore(store tokenstore.Store, user string) {
+	c.OnRefresh(func(newToken string) {
+		_ = store.Set("fitbit-api", user, []byte(newToken))
+	})
+}
+
+// TokenExpiry implements core.AccountWithExpiry.
+func (c *Client) TokenExpiry() time.Time {
+	return c.expiresAt
+}
+
+func (c *Client) postToken(ctx context.Context, form url.Values) error {
+	if c.clientSecret == "" {
+		form.Set("client_id", c.clientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.fitbit.com/oauth2/token", bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if c.clientSecret != "" {
+		req.SetBasicAuth(c.clientID, c.clientSecret)
+	}
+
+	res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("fitbit: oauth2 token request failed: %s", res.Status)
+	}
+
+	var token struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return err
+	}
+
+	c.accessToken = token.AccessToken
+	c.refreshToken = token.RefreshToken
+	c.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+
+	if c.onRefresh != nil {
+		c.onRefresh(c.Token())
+	}
+
+	return nil
+}
+
+// Token returns an opaque string carrying the refresh token and current
+// access token, for LoginWithToken to resume from.
+func (c *Client) Token() string {
+	data, _ := json.Marshal(struct {
+		AccessToken  string    `json:"access_token"`
+		RefreshToken string    `json:"refresh_token"`
+		ExpiresAt    time.Time `json:"expires_at"`
+	}{c.accessToken, c.refreshToken, c.expiresAt})
+	return string(data)
+}
+
+// LoginWithToken restores a session saved via Token, refreshing it first if
+// it has already expired.
+func (c *Client) LoginWithToken(ctx context.Context, token string) error {
+	var v struct {
+		AccessToken  string    `json:"access_token"`
+		RefreshToken string    `json:"refresh_token"`
+		ExpiresAt    time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal([]byte(token), &v); err != nil {
+		return err
+	}
+
+	c.accessToken = v.AccessToken
+	c.refreshToken = v.RefreshToken
+	c.expiresAt = v.ExpiresAt
+
+	if time.Now().After(c.expiresAt) {
+		return c.Refresh(ctx)
+	}
+
+	return nil
+}
+
+func (c *Client) request(ctx context.Context, method, api string, body url.Values) ([]byte, error) {
+	var rd *bytes.Buffer
+	if body != nil {
+		rd = bytes.NewBufferString(body.Encode())
+	} else {
+		rd = bytes.NewBuffer(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, "https://api.fitbit.com"+api, rd)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	data := new(bytes.Buffer)
+	if _, err = data.ReadFrom(res.Body); err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("fitbit: %s %s failed: %s", method, api, res.Status)
+	}
+
+	return data.Bytes(), nil
+}
+
+// maxWindow is the widest date range the weight/fat log endpoints accept
+// per request.
+const maxWindow = 31 * 24 * time.Hour
+
+// GetAllWeights returns Fitbit's weigh-ins, merging the weight and body-fat
+// logs (Fitbit tracks them as two separate resources) into one record per
+// date: when a date has several weigh-ins, the last one listed replaces the
+// earlier ones. It walks
+// backward from today in maxWindow-sized steps, since a single request can
+// only span one month, stopping once a window comes back empty.
+func (c *Client) GetAllWeights(ctx context.Context) ([]*core.Weight, error) {
+	byDate := map[string]*core.Weight{}
+
+	for end := time.Now(); ; end = end.Add(-maxWindow - 24*time.Hour) {
+		start := end.Add(-maxWindow)
+
+		api := fmt.Sprintf("/1/user/-/body/log/weight/date/%s/%s.json", start.Format("2006-01-02"), end.Format("2006-01-02"))
+		data, err := c.request(ctx, "GET", api, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var res1 struct {
+			Weight []struct {
+				LogID  int64   `json:"logId"`
+				Date   string  `json:"date"` // "2024-01-15"
+				Time   string  `json:"time"` // "07:30:00"
+				Weight float32 `json:"weight"`
+				BMI    float32 `json:"bmi"`
+				Source string  `json:"source"`
+			} `json:"weight"`
+		}
+		if err = json.Unmarshal(data, &res1); err != nil {
+			return nil, err
+		}
+
+		for _, v := range res1.Weight {
+			date, _ := time.Parse("2006-01-02 15:04:05", v.Date+" "+v.Time)
+			w := c.fromUnits(&core.Weight{Date: date, Weight: v.Weight, BMI: v.BMI, Source: v.Source})
+			byDate[v.Date] = w
+			c.logIDs[v.Date] = strconv.FormatInt(v.LogID, 10)
+		}
+
+		api = fmt.Sprintf("/1/user/-/body/log/fat/date/%s/%s.json", start.Format("2006-01-02"), end.Format("2006-01-02"))
+		data, err = c.request(ctx, "GET", api, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var res2 struct {
+			Fat []struct {
+				Date string  `json:"date"`
+				Time string  `json:"time"`
+				Fat  float32 `json:"fat"`
+			} `json:"fat"`
+		}
+		if err = json.Unmarshal(data, &res2); err != nil {
+			return nil, err
+		}
+
+		for _, v := range res2.Fat {
+			w, ok := byDate[v.Date]
+			if !ok {
+				date, _ := time.Parse("2006-01-02 15:04:05", v.Date+" "+v.Time)
+				w = &core.Weight{Date: date}
+				byDate[v.Date] = w
+			}
+			w.BodyFat = v.Fat
+		}
+
+		if len(res1.Weight) == 0 && len(res2.Fat) == 0 {
+			break
+		}
+	}
+
+	weights := make([]*core.Weight, 0, len(byDate))
+	for _, w := range byDate {
+		weights = append(weights, w)
+	}
+
+	return weights, nil
+}
+
+// AddWeights pushes weigh-ins missing from Fitbit, same as PushWeights.
+// Named to match core.AccountWithAddWeights, which internal.appendAccount
+// drives the same way it drives garmin.Client.AddWeights.
+func (c *Client) AddWeights(ctx context.Context, weights []*core.Weight) error {
+	return c.PushWeights(ctx, weights)
+}
+
+// DeleteWeight removes the weigh-in logged for weight.Date. The log ID is
+// only known for dates already seen via GetAllWeights in this session.
+func (c *Client) DeleteWeight(ctx context.Context, weight *core.Weight) error {
+	date := weight.Date.Format("2006-01-02")
+
+	logID, ok := c.logIDs[date]
+	if !ok {
+		return errors.New("fitbit: weight not exist")
+	}
+
+	if _, err := c.request(ctx, "DELETE", "/1/user/-/body/log/weight/"+logID+".json", nil); err != nil {
+		return err
+	}
+
+	delete(c.logIDs, date)
+
+	return nil
+}
+
+// Equal reports whether two weigh-ins represent the same Fitbit record,
+// tolerating the float rounding Fitbit's API does on the way back.
+func (c *Client) Equal(w1, w2 *core.Weight) bool {
+	return equalFloat(w1.Weight, w2.Weight) && equalFloat(w1.BMI, w2.BMI) && equalFloat(w1.BodyFat, w2.BodyFat)
+}
+
+func equalFloat(f1, f2 float32) bool {
+	const e = 0.01
+	return f1 > f2-e && f1 < f2+e
+}
+
+// PushWeights writes each weigh-in to the Fitbit weight log, plus the fat
+// log when BodyFat is set.
+func (c *Client) PushWeights(ctx context.Context, weights []*core.Weight) error {
+	for _, w := range weights {
+		w = c.toUnits(w)
+		date := w.Date.Format("2006-01-02")
+		time_ := w.Date.Format("15:04:05")
+
+		if w.Weight != 0 {
+			form := url.Values{
+				"weight": {strconv.FormatFloat(float64(w.Weight), 'f', -1, 32)},
+				"date":   {date},
+				"time":   {time_},
+			}
+			if _, err := c.request(ctx, "POST", "/1/user/-/body/log/weight.json", form); err != nil {
+				return err
+			}
+		}
+
+		if w.BodyFat != 0 {
+			form := url.Values{
+				"fat":  {strconv.FormatFloat(float64(w.BodyFat), 'f', -1, 32)},
+				"date": {date},
+				"time": {time_},
+			}
+			if _, err := c.request(ctx, "POST", "/1/user/-/body/log/fat.json", form); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+const lbPerKg = 1 / LBS2KG
+
+// fromUnits converts a weigh-in read from Fitbit (lb when the account's unit
+// preference is imperial) into the kg core.Weight expects.
+func (c *Client) fromUnits(w *core.Weight) *core.Weight {
+	if c.Pounds {
+		w.Weight *= LBS2KG
+	}
+	return w
+}
+
+// toUnits converts a core.Weight (always kg) into the unit PushWeights must
+// send, per the account's unit preference.
+func (c *Client) toUnits(w *core.Weight) *core.Weight {
+	if !c.Pounds {
+		return w
+	}
+	w2 := *w
+	w2.Weight *= lbPerKg
+	return &w2
+}
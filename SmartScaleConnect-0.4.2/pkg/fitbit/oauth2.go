@@ -0,0 +1,85 @@
+package fitbit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strconv"
+
+	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+)
+
+// RunLocalAuthServer opens the system browser to authorizeURL, listens on
+// 127.0.0.1:port/callback for Fitbit's OAuth2 redirect, checks the returned
+// state against CSRF, and returns the authorization code - the same
+// local-listener pattern xiaomi.RunLocalAuthServer uses for its own
+// authorization-code flow.
+func RunLocalAuthServer(ctx context.Context, port int, authorizeURL, state string) (code string, err error) {
+	addr := "127.0.0.1:" + strconv.Itoa(port)
+
+	codeCh := make(chan string, 1)
+	errCh := make(chan error, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		if got := q.Get("state"); got != state {
+			http.Error(w, "state mismatch", http.StatusBadRequest)
+			errCh <- errors.New("fitbit: oauth2 state mismatch")
+			return
+		}
+
+		if msg := q.Get("error"); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			errCh <- errors.New("fitbit: oauth2 error: " + msg)
+			return
+		}
+
+		_, _ = w.Write([]byte("Login complete, you can close this tab."))
+		codeCh <- q.Get("code")
+	})
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+	}()
+	defer server.Close()
+
+	openBrowser(authorizeURL)
+
+	select {
+	case code = <-codeCh:
+		return code, nil
+	case err = <-errCh:
+		return "", err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// openBrowser is best-effort: callers should also log authorizeURL themselves
+// in case no GUI is available to open it automatically.
+func openBrowser(authorizeURL string) {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", authorizeURL)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", authorizeURL)
+	default:
+		cmd = exec.Command("xdg-open", authorizeURL)
+	}
+
+	_ = cmd.Start()
+}
+
+// RandState generates a URL-safe random state value for AuthorizeURL.
+func RandState() string {
+	return core.RandString(24, 62)
+}
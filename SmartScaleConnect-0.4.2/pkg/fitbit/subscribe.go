@@ -0,0 +1,124 @@
+package fitbit
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+)
+
+// Subscribe registers a subscription for this user's "body" collection
+// (weight + fat logs), implementing core.Subscribable. userTag becomes the
+// subscription ID Fitbit echoes back in every notification for this user,
+// see https://dev.fitbit.com/build/reference/web-api/subscription/.
+func (c *Client) Subscribe(ctx context.Context, userTag string) error {
+	api := "/1/user/-/body/apiSubscriptions/" + url.PathEscape(userTag) + ".json"
+	_, err := c.request(ctx, "POST", api, nil)
+	return err
+}
+
+// notification is one entry of the JSON array Fitbit POSTs to a
+// subscription's callback URL - it names what changed, not the new value.
+type notification struct {
+	CollectionType string `json:"collectionType"`
+	OwnerID        string `json:"ownerId"`
+	SubscriptionID string `json:"subscriptionId"`
+	Date           string `json:"date"` // "2024-01-15"
+}
+
+// HandleNotification implements core.Subscribable. Since Fitbit's
+// notifications carry no weight data themselves, each distinct "body"
+// collection date is resolved by re-fetching that day's weight and fat
+// logs, the same two endpoints GetAllWeights pages through.
+func (c *Client) HandleNotification(ctx context.Context, payload []byte) ([]*core.Weight, error) {
+	var notifications []notification
+	if err := json.Unmarshal(payload, &notifications); err != nil {
+		return nil, err
+	}
+
+	dates := map[string]bool{}
+	for _, n := range notifications {
+		if n.CollectionType == "body" {
+			dates[n.Date] = true
+		}
+	}
+
+	var weights []*core.Weight
+	for date := range dates {
+		day, err := c.weightsOnDate(ctx, date)
+		if err != nil {
+			return nil, err
+		}
+		weights = append(weights, day...)
+	}
+
+	return weights, nil
+}
+
+// weightsOnDate fetches a single day's weight and fat logs, merging them by
+// time of day, so unlike GetAllWeights (one record per date) it keeps
+// several weigh-ins on the same day apart.
+func (c *Client) weightsOnDate(ctx context.Context, date string) ([]*core.Weight, error) {
+	byTime := map[string]*core.Weight{}
+
+	data, err := c.request(ctx, "GET", "/1/user/-/body/log/weight/date/"+date+".json", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var res1 struct {
+		Weight []struct {
+			LogID  int64   `json:"logId"`
+			Date   string  `json:"date"`
+			Time   string  `json:"time"`
+			Weight float32 `json:"weight"`
+			BMI    float32 `json:"bmi"`
+			Source string  `json:"source"`
+		} `json:"weight"`
+	}
+	if err = json.Unmarshal(data, &res1); err != nil {
+		return nil, err
+	}
+
+	for _, v := range res1.Weight {
+		t, _ := time.Parse("2006-01-02 15:04:05", v.Date+" "+v.Time)
+		byTime[v.Time] = c.fromUnits(&core.Weight{Date: t, Weight: v.Weight, BMI: v.BMI, Source: v.Source})
+		c.logIDs[v.Date] = strconv.FormatInt(v.LogID, 10)
+	}
+
+	data, err = c.request(ctx, "GET", "/1/user/-/body/log/fat/date/"+date+".json", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var res2 struct {
+		Fat []struct {
+			Date string  `json:"date"`
+			Time string  `json:"time"`
+			Fat  float32 `json:"fat"`
+		} `json:"fat"`
+	}
+	if err = json.Unmarshal(data, &res2); err != nil {
+		return nil, err
+	}
+
+	for _, v := range res2.Fat {
+		w, ok := byTime[v.Time]
+		if !ok {
+			t, _ := time.Parse("2006-01-02 15:04:05", v.Date+" "+v.Time)
+			w = &core.Weight{Date: t}
+			byTime[v.Time] = w
+		}
+		w.BodyFat = v.Fat
+	}
+
+	weights := make([]*core.Weight, 0, len(byTime))
+	for _, w := range byTime {
+		weights = append(weights, w)
+	}
+
+	return weights, nil
+}
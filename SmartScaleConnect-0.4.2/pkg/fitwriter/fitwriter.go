@@ -0,0 +1,67 @@
+// Package fitwriter serializes core.Weight records into the Garmin/ANT+ FIT
+// binary format, for apps (Zwift, Connect, etc.) that ingest FIT files
+// directly instead of talking to a scale's API. It builds on the same
+// github.com/muktihari/fit encoder pkg/garmin/fit already uses, rather than
+// hand-rolling the container format.
+package fitwriter
+
+import (
+	"io"
+
+	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+	"github.com/muktihari/fit/encoder"
+	"github.com/muktihari/fit/profile/filedef"
+	"github.com/muktihari/fit/profile/mesgdef"
+	"github.com/muktihari/fit/profile/typedef"
+)
+
+// Write encodes weights as a FIT weight file and writes it to w. Unlike
+// pkg/garmin/fit.WriteWeight, it isn't tied to Garmin's file_id fields, so it
+// can be handed to any FIT consumer.
+func Write(w io.Writer, weights ...*core.Weight) error {
+	file := filedef.NewWeight()
+	file.FileId.Type = typedef.FileWeight
+	file.FileId.Manufacturer = typedef.ManufacturerDevelopment
+	file.FileId.Product = 0
+	file.FileId.SerialNumber = 1
+
+	for _, weight := range weights {
+		scale := mesgdef.NewWeightScale(nil)
+		scale.Timestamp = weight.Date
+		scale.Weight = typedef.Weight(weight.Weight * 100)
+
+		if weight.BMI != 0 {
+			scale.Bmi = uint16(weight.BMI * 10)
+		}
+		if weight.BodyFat != 0 {
+			scale.PercentFat = uint16(weight.BodyFat * 100)
+		}
+		if weight.BodyWater != 0 {
+			scale.PercentHydration = uint16(weight.BodyWater * 100)
+		}
+		if weight.BoneMass != 0 {
+			scale.BoneMass = uint16(weight.BoneMass * 100)
+		}
+
+		if weight.MetabolicAge != 0 {
+			scale.MetabolicAge = uint8(weight.MetabolicAge)
+		}
+		if weight.SkeletalMuscleMass != 0 {
+			scale.MuscleMass = uint16(weight.SkeletalMuscleMass * 100)
+		}
+		if weight.PhysiqueRating != 0 {
+			scale.PhysiqueRating = uint8(weight.PhysiqueRating)
+		}
+		if weight.VisceralFat != 0 {
+			scale.VisceralFatRating = uint8(weight.VisceralFat)
+		}
+		if weight.BasalMetabolism != 0 {
+			scale.BasalMet = uint16(weight.BasalMetabolism * 4)
+		}
+
+		file.WeightScales = append(file.WeightScales, scale)
+	}
+
+	fit := file.ToFIT(nil)
+	return encoder.New(w).Encode(&fit)
+}
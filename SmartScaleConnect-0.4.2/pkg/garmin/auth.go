@@ -1,6 +1,7 @@
 package garmin
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,25 +12,65 @@ import (
 	"time"
 
 	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+	"github.com/AlexxIT/SmartScaleConnect/pkg/httpx"
 	"github.com/gomodule/oauth1/oauth"
 )
 
-func (c *Client) Login(username, password string) error {
-	ticket, err := c.getTicket(username, password)
+// ErrMFARequired is returned by Login when the account has Garmin Connect
+// two-factor auth enabled and no remembered mfa-token cookie covers this
+// signin - call LoginMFA instead.
+var ErrMFARequired = errors.New("garmin: mfa required, use LoginMFA")
+
+// ssoURL is the cookie domain Garmin's "remember this device" mfa-token is
+// scoped to.
+var ssoURL = &url.URL{Scheme: "https", Host: "sso.garmin.com"}
+
+func (c *Client) Login(ctx context.Context, username, password string) error {
+	ticket, err := c.getTicket(ctx, username, password, nil)
+	if err != nil {
+		return err
+	}
+	if err = c.getCredentials(ctx, ticket); err != nil {
+		return err
+	}
+	c.persistToken()
+	return nil
+}
+
+// LoginMFA is Login's superset for accounts with Garmin Connect two-factor
+// auth enabled: prompt is called, with no arguments, once Garmin's response
+// carries an MFA challenge instead of a ticket, and must return the 6-digit
+// code the user received. The challenge page's fresh CSRF token is sent
+// along with that code internally.
+func (c *Client) LoginMFA(ctx context.Context, username, password string, prompt func() (string, error)) error {
+	ticket, err := c.getTicket(ctx, username, password, prompt)
 	if err != nil {
 		return err
 	}
-	return c.getCredentials(ticket)
+	if err = c.getCredentials(ctx, ticket); err != nil {
+		return err
+	}
+	c.persistToken()
+	return nil
 }
 
-// getTicket - first stage exchange username and password to OAuth ticket
-func (c *Client) getTicket(username, password string) (string, error) {
+// getTicket - first stage exchange username and password to OAuth ticket.
+// prompt is only consulted if Garmin's signin response is an MFA challenge;
+// nil means the caller (plain Login) doesn't support one, so a challenge
+// surfaces as ErrMFARequired.
+func (c *Client) getTicket(ctx context.Context, username, password string, prompt func() (string, error)) (string, error) {
+	c.restoreMFAToken()
+
 	const url1 = "https://sso.garmin.com/sso/embed?" +
 		"id=gauth-widget&" +
 		"embedWidget=true&" +
 		"gauthHost=https://sso.garmin.com/sso"
 
-	res, err := c.client.Get(url1)
+	req1, err := http.NewRequestWithContext(ctx, "GET", url1, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := c.client.Do(req1)
 	if err != nil {
 		return "", err
 	}
@@ -45,7 +86,11 @@ func (c *Client) getTicket(username, password string) (string, error) {
 		"service=https://sso.garmin.com/sso/embed&" +
 		"source=https://sso.garmin.com/sso/embed"
 
-	res, err = c.client.Get(url2)
+	req2, err := http.NewRequestWithContext(ctx, "GET", url2, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err = c.client.Do(req2)
 	if err != nil {
 		return "", err
 	}
@@ -64,7 +109,7 @@ func (c *Client) getTicket(username, password string) (string, error) {
 		url.QueryEscape(username), url.QueryEscape(password), csrf,
 	)
 
-	req, err := http.NewRequest("POST", url2, strings.NewReader(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", url2, strings.NewReader(data))
 	if err != nil {
 		return "", err
 	}
@@ -83,23 +128,110 @@ func (c *Client) getTicket(username, password string) (string, error) {
 		return "", err
 	}
 
+	if ticket := core.Between(string(body), `embed?ticket=`, `"`); ticket != "" {
+		c.storeMFAToken()
+		return ticket, nil
+	}
+
+	if msg := core.Between(string(body), `class="error">`, `<`); msg != "" {
+		return "", errors.New("garmin: " + msg)
+	}
+
+	if !strings.Contains(string(body), `name="mfa-code"`) {
+		return "", errors.New("garmin: can't find ticket")
+	}
+
+	if prompt == nil {
+		return "", ErrMFARequired
+	}
+
+	return c.verifyMFA(ctx, string(body), prompt)
+}
+
+// verifyMFA completes the signin Garmin's MFA challenge started: it prompts
+// for the 6-digit code and posts it, with the fresh CSRF token carried in
+// signinBody and the mfa-token cookie the jar already picked up from the
+// challenge response, to loginEnterMfaCode.
+func (c *Client) verifyMFA(ctx context.Context, signinBody string, prompt func() (string, error)) (string, error) {
+	csrf := core.Between(signinBody, `name="_csrf" value="`, `"`)
+	if csrf == "" {
+		return "", errors.New("garmin: mfa: can't find csrf token")
+	}
+
+	code, err := prompt()
+	if err != nil {
+		return "", err
+	}
+
+	const url3 = "https://sso.garmin.com/sso/verifyMFA/loginEnterMfaCode?" +
+		"id=gauth-widget&" +
+		"embedWidget=true&" +
+		"gauthHost=https://sso.garmin.com/sso/embed&" +
+		"service=https://sso.garmin.com/sso/embed&" +
+		"source=https://sso.garmin.com/sso/embed"
+
+	data := fmt.Sprintf("mfa-code=%s&embed=true&_csrf=%s", url.QueryEscape(code), csrf)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url3, strings.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", url3)
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+
 	ticket := core.Between(string(body), `embed?ticket=`, `"`)
 	if ticket == "" {
-		if msg := core.Between(string(body), `class="error">`, `<`); msg != "" {
-			return "", errors.New("garmin: " + msg)
-		}
-		return "", errors.New("garmin: can't find ticket")
+		return "", errors.New("garmin: mfa: can't find ticket")
 	}
 
+	c.storeMFAToken()
+
 	return ticket, nil
 }
 
-func (c *Client) initOAuth() error {
+// restoreMFAToken re-applies a previously remembered mfa-token cookie to
+// the client's jar, so Garmin can recognize this device and skip the MFA
+// challenge again within the cookie's validity window.
+func (c *Client) restoreMFAToken() {
+	if c.mfaToken == "" {
+		return
+	}
+	c.client.Jar.SetCookies(ssoURL, []*http.Cookie{{Name: "mfa-token", Value: c.mfaToken}})
+}
+
+// storeMFAToken captures the mfa-token cookie Garmin just set, if any, so
+// Token() can persist it for a later Login to restore.
+func (c *Client) storeMFAToken() {
+	for _, cookie := range c.client.Jar.Cookies(ssoURL) {
+		if cookie.Name == "mfa-token" {
+			c.mfaToken = cookie.Value
+			return
+		}
+	}
+}
+
+func (c *Client) initOAuth(ctx context.Context) error {
 	if c.oauthClient != nil {
 		return nil
 	}
 
-	res, err := http.Get("https://thegarth.s3.amazonaws.com/oauth_consumer.json")
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://thegarth.s3.amazonaws.com/oauth_consumer.json", nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -125,8 +257,8 @@ func (c *Client) initOAuth() error {
 }
 
 // getCredentials - first stage exchange ticket to OAuth Token and Secret
-func (c *Client) getCredentials(ticket string) error {
-	if err := c.initOAuth(); err != nil {
+func (c *Client) getCredentials(ctx context.Context, ticket string) error {
+	if err := c.initOAuth(ctx); err != nil {
 		return err
 	}
 
@@ -138,7 +270,7 @@ func (c *Client) getCredentials(ticket string) error {
 		ticket,
 	)
 
-	req, err := http.NewRequest("GET", url1, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url1, nil)
 	if err != nil {
 		return err
 	}
@@ -170,14 +302,14 @@ func (c *Client) getCredentials(ticket string) error {
 }
 
 // refreshAccessToken - exchange OAuth Token and Secret to accessToken
-func (c *Client) refreshAccessToken() error {
-	if err := c.initOAuth(); err != nil {
+func (c *Client) refreshAccessToken(ctx context.Context) error {
+	if err := c.initOAuth(ctx); err != nil {
 		return err
 	}
 
 	const url1 = "https://connectapi.garmin.com/oauth-service/oauth/exchange/user/2.0"
 
-	req, err := http.NewRequest("POST", url1, nil)
+	req, err := http.NewRequestWithContext(ctx, "POST", url1, nil)
 	if err != nil {
 		return err
 	}
@@ -207,24 +339,34 @@ func (c *Client) refreshAccessToken() error {
 	c.accessToken = data.AccessToken
 	c.expiresTime = time.Now().Add(time.Duration(data.ExpiresIn) * time.Second)
 
+	c.persistToken()
+
 	return nil
 }
 
-func (c *Client) do(req *http.Request) (*http.Response, error) {
+func (c *Client) do(ctx context.Context, req *http.Request, policy httpx.Policy) (*http.Response, error) {
 	if c.accessToken == "" || time.Now().After(c.expiresTime) {
-		if err := c.refreshAccessToken(); err != nil {
+		if err := c.refreshAccessToken(ctx); err != nil {
 			return nil, err
 		}
 	}
 
 	req.Header.Add("Authorization", "Bearer "+c.accessToken)
-	return c.client.Do(req)
+	return httpx.Do(ctx, c.client, req, policy)
 }
 
-func (c *Client) LoginWithToken(token string) error {
-	c.oauthToken, c.oauthSecret, _ = strings.Cut(token, ":")
+func (c *Client) LoginWithToken(ctx context.Context, token string) error {
+	parts := strings.SplitN(token, ":", 3)
+	c.oauthToken = parts[0]
+	if len(parts) > 1 {
+		c.oauthSecret = parts[1]
+	}
+	if len(parts) > 2 {
+		c.mfaToken, _ = url.QueryUnescape(parts[2])
+		c.restoreMFAToken()
+	}
 
-	res, err := c.Get("userprofile-service/userprofile/userProfileBase")
+	res, err := c.Get(ctx, "userprofile-service/userprofile/userProfileBase")
 	if err != nil {
 		return err
 	}
@@ -238,5 +380,5 @@ func (c *Client) LoginWithToken(token string) error {
 }
 
 func (c *Client) Token() string {
-	return c.oauthToken + ":" + c.oauthSecret
+	return c.oauthToken + ":" + c.oauthSecret + ":" + url.QueryEscape(c.mfaToken)
 }
@@ -2,6 +2,7 @@ package garmin
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,6 +13,8 @@ import (
 
 	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
 	"github.com/AlexxIT/SmartScaleConnect/pkg/garmin/fit"
+	"github.com/AlexxIT/SmartScaleConnect/pkg/httpx"
+	"github.com/AlexxIT/SmartScaleConnect/pkg/tokenstore"
 	"github.com/gomodule/oauth1/oauth"
 )
 
@@ -25,9 +28,33 @@ type Client struct {
 	accessToken string
 	expiresTime time.Time
 
+	// mfaToken is Garmin's "remember this device" cookie value, set once an
+	// MFA challenge is solved via LoginMFA. Restoring it on a later Login
+	// lets Garmin skip the challenge again within its validity window.
+	mfaToken string
+
+	tokenStore tokenstore.Store
+	tokenUser  string
+
 	weightID map[int64]string
 }
 
+// SetTokenStore wires store as c's persistence backend, namespaced under
+// user: every Login/LoginMFA and refreshAccessToken that rotates c's token
+// saves the new blob to store immediately, so the caller doesn't have to
+// serialize Token() back to disk by hand after every sync.
+func (c *Client) SetTokenStore(store tokenstore.Store, user string) {
+	c.tokenStore = store
+	c.tokenUser = user
+}
+
+func (c *Client) persistToken() {
+	if c.tokenStore == nil {
+		return
+	}
+	_ = c.tokenStore.Set("garmin", c.tokenUser, []byte(c.Token()))
+}
+
 func NewClient() *Client {
 	jar, _ := cookiejar.New(nil)
 	return &Client{
@@ -36,23 +63,23 @@ func NewClient() *Client {
 	}
 }
 
-func (c *Client) Get(api string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", "https://connectapi.garmin.com/"+api, nil)
+func (c *Client) Get(ctx context.Context, api string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://connectapi.garmin.com/"+api, nil)
 	if err != nil {
 		return nil, err
 	}
-	return c.do(req)
+	return c.do(ctx, req, httpx.DefaultPolicy)
 }
 
-func (c *Client) Delete(api string) (*http.Response, error) {
-	req, err := http.NewRequest("DELETE", "https://connectapi.garmin.com/"+api, nil)
+func (c *Client) Delete(ctx context.Context, api string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", "https://connectapi.garmin.com/"+api, nil)
 	if err != nil {
 		return nil, err
 	}
-	return c.do(req)
+	return c.do(ctx, req, httpx.DefaultPolicy)
 }
 
-func (c *Client) PostFile(api, filename string, data []byte) (*http.Response, error) {
+func (c *Client) PostFile(ctx context.Context, api, filename string, data []byte) (*http.Response, error) {
 	buf := bytes.NewBuffer(nil)
 	w := multipart.NewWriter(buf)
 	part, err := w.CreateFormFile("file", filename)
@@ -68,17 +95,22 @@ func (c *Client) PostFile(api, filename string, data []byte) (*http.Response, er
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", "https://connectapi.garmin.com/"+api, buf)
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://connectapi.garmin.com/"+api, buf)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Add("Content-Type", w.FormDataContentType())
 
-	return c.do(req)
+	// Garmin dedups uploads server-side, so retrying this POST on a
+	// transient error is safe.
+	policy := httpx.DefaultPolicy
+	policy.RetryPost = true
+
+	return c.do(ctx, req, policy)
 }
 
-func (c *Client) Upload(filename string, data []byte) error {
-	res, err := c.PostFile("upload-service/upload", filename, data)
+func (c *Client) Upload(ctx context.Context, filename string, data []byte) error {
+	res, err := c.PostFile(ctx, "upload-service/upload", filename, data)
 	if err != nil {
 		return err
 	}
@@ -91,14 +123,14 @@ func (c *Client) Upload(filename string, data []byte) error {
 	return nil
 }
 
-func (c *Client) GetAllWeights() ([]*core.Weight, error) {
-	return c.GetWeight("1970-01-01", time.Now().Format(time.DateOnly))
+func (c *Client) GetAllWeights(ctx context.Context) ([]*core.Weight, error) {
+	return c.GetWeight(ctx, "1970-01-01", time.Now().Format(time.DateOnly))
 }
 
 // GetWeight - start and end format: 2025-07-28
-func (c *Client) GetWeight(start, end string) ([]*core.Weight, error) {
+func (c *Client) GetWeight(ctx context.Context, start, end string) ([]*core.Weight, error) {
 	path := fmt.Sprintf("weight-service/weight/range/%s/%s?includeAll=true", start, end)
-	res, err := c.Get(path)
+	res, err := c.Get(ctx, path)
 	if err != nil {
 		return nil, err
 	}
@@ -165,7 +197,7 @@ func (c *Client) GetWeight(start, end string) ([]*core.Weight, error) {
 	return weights, nil
 }
 
-func (c *Client) AddWeights(weights []*core.Weight) error {
+func (c *Client) AddWeights(ctx context.Context, weights []*core.Weight) error {
 	if len(c.weightID) == 0 {
 		return nil
 	}
@@ -187,7 +219,7 @@ func (c *Client) AddWeights(weights []*core.Weight) error {
 			return err
 		}
 
-		if err := c.Upload("new.fit", buf.Bytes()); err != nil {
+		if err := c.Upload(ctx, "new.fit", buf.Bytes()); err != nil {
 			return err
 		}
 	}
@@ -195,13 +227,13 @@ func (c *Client) AddWeights(weights []*core.Weight) error {
 	return nil
 }
 
-func (c *Client) DeleteWeight(weight *core.Weight) error {
+func (c *Client) DeleteWeight(ctx context.Context, weight *core.Weight) error {
 	weightID, ok := c.weightID[weight.Date.UnixMilli()]
 	if !ok {
 		return errors.New("garmin: weight not exist")
 	}
 
-	res, err := c.Delete("weight-service/weight/" + weightID)
+	res, err := c.Delete(ctx, "weight-service/weight/"+weightID)
 	if err != nil {
 		return err
 	}
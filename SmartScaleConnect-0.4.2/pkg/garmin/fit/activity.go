@@ -0,0 +1,70 @@
+package fit
+
+import (
+	"io"
+
+	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+	"github.com/muktihari/fit/encoder"
+	"github.com/muktihari/fit/profile/filedef"
+	"github.com/muktihari/fit/profile/mesgdef"
+	"github.com/muktihari/fit/profile/typedef"
+)
+
+// sportFor maps a core.Activity.Type (free-form, vendor-reported) to the
+// closest typedef.Sport, falling back to generic for anything unrecognized
+// so the session is still importable.
+func sportFor(activityType string) typedef.Sport {
+	switch activityType {
+	case "running":
+		return typedef.SportRunning
+	case "cycling":
+		return typedef.SportCycling
+	case "swimming":
+		return typedef.SportSwimming
+	case "walking":
+		return typedef.SportWalking
+	default:
+		return typedef.SportGeneric
+	}
+}
+
+func WriteActivity(w io.Writer, activities ...*core.Activity) error {
+	file := filedef.NewActivity()
+	file.FileId.Type = typedef.FileActivity
+	file.FileId.Manufacturer = typedef.ManufacturerGarmin
+	file.FileId.Product = 2429
+	file.FileId.SerialNumber = 1234
+
+	for _, activity := range activities {
+		session := mesgdef.NewSession(nil)
+		session.StartTime = activity.Start
+		session.Timestamp = activity.Start.Add(activity.Duration)
+		session.Sport = sportFor(activity.Type)
+		session.TotalElapsedTime = uint32(activity.Duration.Seconds() * 1000)
+		session.TotalTimerTime = uint32(activity.Duration.Seconds() * 1000)
+
+		if activity.Distance != 0 {
+			session.TotalDistance = uint32(activity.Distance * 100)
+		}
+		if activity.Calories != 0 {
+			session.TotalCalories = uint16(activity.Calories)
+		}
+		if activity.AvgHeartRate != 0 {
+			session.AvgHeartRate = uint8(activity.AvgHeartRate)
+		}
+		if activity.MaxHeartRate != 0 {
+			session.MaxHeartRate = uint8(activity.MaxHeartRate)
+		}
+		if activity.AvgSpeed != 0 {
+			session.AvgSpeed = uint16(activity.AvgSpeed * 1000)
+		}
+		if activity.AvgCadence != 0 {
+			session.AvgCadence = uint8(activity.AvgCadence)
+		}
+
+		file.Sessions = append(file.Sessions, session)
+	}
+
+	fit := file.ToFIT(nil)
+	return encoder.New(w).Encode(&fit)
+}
@@ -0,0 +1,44 @@
+package fit
+
+import (
+	"io"
+
+	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+	"github.com/muktihari/fit/encoder"
+	"github.com/muktihari/fit/profile/filedef"
+	"github.com/muktihari/fit/profile/mesgdef"
+	"github.com/muktihari/fit/profile/typedef"
+)
+
+// WriteMonitoring writes continuous (all-day) heart-rate/step samples as a
+// Garmin monitoring-B file, the same file type Connect's wellness sync
+// expects for background HR and step data (as opposed to a tracked
+// workout, see WriteActivity).
+func WriteMonitoring(w io.Writer, samples ...*core.HeartRateSample) error {
+	file := filedef.NewMonitoringAB()
+	file.FileId.Type = typedef.FileMonitoringB
+	file.FileId.Manufacturer = typedef.ManufacturerGarmin
+	file.FileId.Product = 2429
+	file.FileId.SerialNumber = 1234
+
+	for _, sample := range samples {
+		monitoring := mesgdef.NewMonitoring(nil)
+		monitoring.Timestamp = sample.Date
+
+		if sample.HeartRate != 0 {
+			monitoring.HeartRate = uint8(sample.HeartRate)
+		}
+		if sample.Steps != 0 {
+			// cycles16 is "2 * cycles (steps)" per the FIT profile: a
+			// pedometer counts one cycle per step pair, so halve steps
+			// back into cycles here.
+			monitoring.ActivityType = typedef.ActivityTypeWalking
+			monitoring.Cycles16 = uint16(sample.Steps / 2)
+		}
+
+		file.Monitorings = append(file.Monitorings, monitoring)
+	}
+
+	fit := file.ToFIT(nil)
+	return encoder.New(w).Encode(&fit)
+}
@@ -0,0 +1,55 @@
+package fit
+
+import (
+	"io"
+
+	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+	"github.com/muktihari/fit/encoder"
+	"github.com/muktihari/fit/profile/mesgdef"
+	"github.com/muktihari/fit/profile/typedef"
+	"github.com/muktihari/fit/proto"
+)
+
+// sleepLevelFor maps a core.SleepStage.Level to typedef.SleepLevel, falling
+// back to unmeasurable for anything unrecognized.
+func sleepLevelFor(level string) typedef.SleepLevel {
+	switch level {
+	case "awake":
+		return typedef.SleepLevelAwake
+	case "light":
+		return typedef.SleepLevelLight
+	case "deep":
+		return typedef.SleepLevelDeep
+	case "rem":
+		return typedef.SleepLevelRem
+	default:
+		return typedef.SleepLevelUnmeasurable
+	}
+}
+
+// WriteSleep writes sleeps as sleep_level messages inside a Garmin Activity
+// FIT file - the FIT profile has no dedicated sleep file_id/filedef
+// container, so (unlike WriteWeight/WriteActivity/WriteMonitoring) this
+// assembles proto.FIT's messages by hand instead of going through filedef.
+func WriteSleep(w io.Writer, sleeps ...*core.Sleep) error {
+	fileId := mesgdef.NewFileId(nil)
+	fileId.Type = typedef.FileActivity
+	fileId.Manufacturer = typedef.ManufacturerGarmin
+	fileId.Product = 2429
+	fileId.SerialNumber = 1234
+
+	messages := []proto.Message{fileId.ToMesg(nil)}
+
+	for _, sleep := range sleeps {
+		for _, stage := range sleep.Stages {
+			level := mesgdef.NewSleepLevel(nil)
+			level.Timestamp = stage.Start
+			level.SleepLevel = sleepLevelFor(stage.Level)
+
+			messages = append(messages, level.ToMesg(nil))
+		}
+	}
+
+	fit := proto.FIT{Messages: messages}
+	return encoder.New(w).Encode(&fit)
+}
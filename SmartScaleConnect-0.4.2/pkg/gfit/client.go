@@ -0,0 +1,181 @@
+// Package gfit syncs weigh-ins to Google Fit (fitness/v1), for apps that
+// read from Google Fit's aggregated health timeline instead of a specific
+// scale vendor.
+package gfit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/AlexxIT/SmartScaleConnect/pkg/httpx"
+)
+
+// DefaultScope is the only permission AddWeights/GetAllWeights/DeleteWeight
+// need: read/write access to Google Fit's body data types (weight, body fat
+// percentage, height).
+const DefaultScope = "https://www.googleapis.com/auth/fitness.body.write"
+
+// dataSourceName is the Google Fit "data stream name": the last, free-form
+// component of the data source's stable ID. Every client run derives the
+// same ID from this plus the data type and the OAuth2 client ID (see
+// ensureDataSource), so re-runs reuse the same data source instead of
+// creating duplicates.
+const dataSourceName = "smartscaleconnect"
+
+// Client uploads weigh-ins to Google Fit as a private com.google.weight
+// DataSource, and implements core.AccountWithAddWeights so it can sit next
+// to Garmin/Tanita/etc. as a sync destination.
+type Client struct {
+	client *http.Client
+
+	clientID     string
+	clientSecret string
+
+	accessToken  string
+	refreshToken string
+	expiresAt    time.Time
+
+	dataSourceIDs map[string]string // dataType -> stream ID, lazily resolved by ensureDataSource
+
+	onRefresh func(newToken string)
+}
+
+func NewClient(clientID, clientSecret string) *Client {
+	return &Client{
+		client:       &http.Client{Timeout: time.Minute},
+		clientID:     clientID,
+		clientSecret: clientSecret,
+	}
+}
+
+// Login resumes a session from a refresh token obtained out-of-band via
+// Google's own OAuth2 consent screen, same convention as fitbit.Client.Login.
+func (c *Client) Login(ctx context.Context, clientID, refreshToken string) error {
+	c.clientID = clientID
+	c.refreshToken = refreshToken
+	return c.Refresh(ctx)
+}
+
+func (c *Client) Refresh(ctx context.Context) error {
+	if c.refreshToken == "" {
+		return errors.New("gfit: no refresh token")
+	}
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {c.refreshToken},
+		"client_id":     {c.clientID},
+		"client_secret": {c.clientSecret},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://oauth2.googleapis.com/token", bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("gfit: oauth2 token request failed: %s", res.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return err
+	}
+
+	c.accessToken = token.AccessToken
+	c.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+
+	if c.onRefresh != nil {
+		c.onRefresh(c.Token())
+	}
+
+	return nil
+}
+
+func (c *Client) OnRefresh(fn func(newToken string)) {
+	c.onRefresh = fn
+}
+
+// TokenExpiry implements core.AccountWithExpiry.
+func (c *Client) TokenExpiry() time.Time {
+	return c.expiresAt
+}
+
+func (c *Client) Token() string {
+	data, _ := json.Marshal(struct {
+		RefreshToken string `json:"refresh_token"`
+	}{c.refreshToken})
+	return string(data)
+}
+
+func (c *Client) LoginWithToken(ctx context.Context, token string) error {
+	var v struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal([]byte(token), &v); err != nil {
+		return err
+	}
+	c.refreshToken = v.RefreshToken
+	return c.Refresh(ctx)
+}
+
+func (c *Client) request(ctx context.Context, method, api string, body any) ([]byte, error) {
+	if time.Now().After(c.expiresAt) {
+		if err := c.Refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var rd *bytes.Buffer
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		rd = bytes.NewBuffer(data)
+	} else {
+		rd = bytes.NewBuffer(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, "https://www.googleapis.com/fitness/v1"+api, rd)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	data := new(bytes.Buffer)
+	if _, err = data.ReadFrom(res.Body); err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 300 && res.StatusCode != http.StatusNotFound {
+		return nil, fmt.Errorf("gfit: %s %s failed: %s", method, api, res.Status)
+	}
+
+	return data.Bytes(), nil
+}
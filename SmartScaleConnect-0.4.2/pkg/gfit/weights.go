@@ -0,0 +1,337 @@
+package gfit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+)
+
+// weightDataType is Google Fit's built-in body-weight data type, one
+// fpVal (kg) per point. fatDataType/heightDataType are written/read
+// alongside it whenever a weigh-in carries BodyFat/Height, so a sync
+// round-trips more than just the scale reading.
+const (
+	weightDataType = "com.google.weight"
+	fatDataType    = "com.google.body.fat.percentage"
+	heightDataType = "com.google.body.height.meters"
+)
+
+// dataTypeField is the single fpVal field name Google Fit expects for each
+// of the data types above.
+var dataTypeField = map[string]string{
+	weightDataType: "weight",
+	fatDataType:    "percentage",
+	heightDataType: "height",
+}
+
+// maxPointsPerPatch keeps each dataset.patch request well under Google
+// Fit's payload limit.
+const maxPointsPerPatch = 500
+
+// ensureDataSource returns this account's private data source for dataType,
+// creating it on first use. The ID is derived from clientID plus
+// dataSourceName, so every run against the same Google account resolves to
+// the same source instead of creating duplicates.
+func (c *Client) ensureDataSource(ctx context.Context, dataType string) (string, error) {
+	if id, ok := c.dataSourceIDs[dataType]; ok {
+		return id, nil
+	}
+
+	id := fmt.Sprintf("raw:%s:%s:%s", dataType, c.clientID, dataSourceName)
+
+	if data, err := c.request(ctx, "GET", "/users/me/dataSources/"+id, nil); err != nil {
+		return "", err
+	} else if len(data) > 0 {
+		var res struct {
+			DataStreamID string `json:"dataStreamId"`
+		}
+		if json.Unmarshal(data, &res) == nil && res.DataStreamID != "" {
+			c.storeDataSourceID(dataType, res.DataStreamID)
+			return res.DataStreamID, nil
+		}
+	}
+
+	body := map[string]any{
+		"dataStreamName": dataSourceName,
+		"type":           "raw",
+		"application":    map[string]string{"packageName": c.clientID},
+		"dataType": map[string]any{
+			"name":   dataType,
+			"fields": []map[string]string{{"name": dataTypeField[dataType], "format": "floatPoint"}},
+		},
+	}
+
+	data, err := c.request(ctx, "POST", "/users/me/dataSources", body)
+	if err != nil {
+		return "", err
+	}
+
+	var res struct {
+		DataStreamID string `json:"dataStreamId"`
+	}
+	if err = json.Unmarshal(data, &res); err != nil {
+		return "", err
+	}
+
+	c.storeDataSourceID(dataType, res.DataStreamID)
+
+	return res.DataStreamID, nil
+}
+
+func (c *Client) storeDataSourceID(dataType, id string) {
+	if c.dataSourceIDs == nil {
+		c.dataSourceIDs = map[string]string{}
+	}
+	c.dataSourceIDs[dataType] = id
+}
+
+type point struct {
+	StartTimeNanos string       `json:"startTimeNanos"`
+	EndTimeNanos   string       `json:"endTimeNanos"`
+	DataTypeName   string       `json:"dataTypeName"`
+	Value          []pointValue `json:"value"`
+}
+
+type pointValue struct {
+	FpVal float32 `json:"fpVal"`
+}
+
+func nanos(t time.Time) string {
+	return fmt.Sprintf("%d", t.UnixNano())
+}
+
+// patchPoints ensures dataType's data source exists and patches points into
+// it over [minT, maxT]. It's a no-op for an empty points slice, so a chunk
+// that carries no BodyFat/Height values doesn't create an unused data
+// source.
+func (c *Client) patchPoints(ctx context.Context, dataType string, points []point, minT, maxT time.Time) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	dataSourceID, err := c.ensureDataSource(ctx, dataType)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]any{
+		"dataSourceId":   dataSourceID,
+		"minStartTimeNs": nanos(minT),
+		"maxEndTimeNs":   nanos(maxT),
+		"point":          points,
+	}
+
+	api := fmt.Sprintf("/users/me/dataSources/%s/datasets/%s-%s", dataSourceID, nanos(minT), nanos(maxT))
+	_, err = c.request(ctx, "PATCH", api, body)
+	return err
+}
+
+// AddWeights uploads weigh-ins as Points via dataset.patch, chunking large
+// batches the same way garmin.Client.AddWeights chunks FIT uploads. Each
+// weigh-in's BodyFat/Height, when present, are patched into their own data
+// type alongside the Weight point.
+func (c *Client) AddWeights(ctx context.Context, weights []*core.Weight) error {
+	if len(weights) == 0 {
+		return nil
+	}
+
+	for len(weights) != 0 {
+		var chunk []*core.Weight
+		if len(weights) > maxPointsPerPatch {
+			chunk, weights = weights[:maxPointsPerPatch], weights[maxPointsPerPatch:]
+		} else {
+			chunk, weights = weights, nil
+		}
+
+		minT, maxT := chunk[0].Date, chunk[0].Date
+		var weightPoints, fatPoints, heightPoints []point
+
+		for _, w := range chunk {
+			if w.Date.Before(minT) {
+				minT = w.Date
+			}
+			if w.Date.After(maxT) {
+				maxT = w.Date
+			}
+
+			weightPoints = append(weightPoints, point{
+				StartTimeNanos: nanos(w.Date),
+				EndTimeNanos:   nanos(w.Date),
+				DataTypeName:   weightDataType,
+				Value:          []pointValue{{FpVal: w.Weight}},
+			})
+
+			if w.BodyFat != 0 {
+				fatPoints = append(fatPoints, point{
+					StartTimeNanos: nanos(w.Date),
+					EndTimeNanos:   nanos(w.Date),
+					DataTypeName:   fatDataType,
+					Value:          []pointValue{{FpVal: w.BodyFat / 100}}, // Google Fit wants a 0-1 fraction
+				})
+			}
+
+			if w.Height != 0 {
+				heightPoints = append(heightPoints, point{
+					StartTimeNanos: nanos(w.Date),
+					EndTimeNanos:   nanos(w.Date),
+					DataTypeName:   heightDataType,
+					Value:          []pointValue{{FpVal: w.Height / 100}}, // Google Fit wants meters, core.Weight stores cm
+				})
+			}
+		}
+
+		if err := c.patchPoints(ctx, weightDataType, weightPoints, minT, maxT); err != nil {
+			return err
+		}
+		if err := c.patchPoints(ctx, fatDataType, fatPoints, minT, maxT); err != nil {
+			return err
+		}
+		if err := c.patchPoints(ctx, heightDataType, heightPoints, minT, maxT); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteWeight removes the single point at weight.Date by patching a
+// zero-length dataset over its exact nanosecond range, per Google Fit's
+// documented deletion method (there's no per-point DELETE). It clears
+// BodyFat/Height's data types too when weight carries those fields, so a
+// replace (DeleteWeight followed by AddWeights) doesn't leave a stale point
+// behind in either.
+func (c *Client) DeleteWeight(ctx context.Context, weight *core.Weight) error {
+	ns := nanos(weight.Date)
+
+	dataTypes := []string{weightDataType}
+	if weight.BodyFat != 0 {
+		dataTypes = append(dataTypes, fatDataType)
+	}
+	if weight.Height != 0 {
+		dataTypes = append(dataTypes, heightDataType)
+	}
+
+	for _, dataType := range dataTypes {
+		dataSourceID, err := c.ensureDataSource(ctx, dataType)
+		if err != nil {
+			return err
+		}
+
+		body := map[string]any{
+			"dataSourceId":   dataSourceID,
+			"minStartTimeNs": ns,
+			"maxEndTimeNs":   ns,
+			"point":          []point{},
+		}
+
+		api := fmt.Sprintf("/users/me/dataSources/%s/datasets/%s-%s", dataSourceID, ns, ns)
+		if _, err = c.request(ctx, "PATCH", api, body); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Equal reports whether two weigh-ins are the same point, tolerating the
+// float rounding Google Fit's API does on the way back.
+func (c *Client) Equal(w1, w2 *core.Weight) bool {
+	const e = 0.01
+	return w1.Weight > w2.Weight-e && w1.Weight < w2.Weight+e
+}
+
+// GetAllWeights fetches the full history in one dataset:aggregate request,
+// bucketed by day, so appendAccount's date-based dedup lines up symmetrically with
+// AddWeights/DeleteWeight. BodyFat/Height points are merged back onto the
+// matching Weight point by timestamp when present.
+func (c *Client) GetAllWeights(ctx context.Context) ([]*core.Weight, error) {
+	weightSourceID, err := c.ensureDataSource(ctx, weightDataType)
+	if err != nil {
+		return nil, err
+	}
+	fatSourceID, err := c.ensureDataSource(ctx, fatDataType)
+	if err != nil {
+		return nil, err
+	}
+	heightSourceID, err := c.ensureDataSource(ctx, heightDataType)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]any{
+		"aggregateBy": []map[string]string{
+			{"dataTypeName": weightDataType, "dataSourceId": weightSourceID},
+			{"dataTypeName": fatDataType, "dataSourceId": fatSourceID},
+			{"dataTypeName": heightDataType, "dataSourceId": heightSourceID},
+		},
+		"bucketByTime":    map[string]any{"durationMillis": 24 * 3600 * 1000},
+		"startTimeMillis": "0",
+		"endTimeMillis":   fmt.Sprintf("%d", time.Now().UnixMilli()),
+	}
+
+	data, err := c.request(ctx, "POST", "/users/me/dataset:aggregate", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Bucket []struct {
+			Dataset []struct {
+				DataSourceID string `json:"dataSourceId"`
+				Point        []struct {
+					StartTimeNanos string       `json:"startTimeNanos"`
+					Value          []pointValue `json:"value"`
+				} `json:"point"`
+			} `json:"dataset"`
+		} `json:"bucket"`
+	}
+	if err = json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+
+	byTime := map[int64]*core.Weight{}
+	var order []int64
+
+	for _, bucket := range res.Bucket {
+		for _, ds := range bucket.Dataset {
+			for _, p := range ds.Point {
+				if len(p.Value) == 0 {
+					continue
+				}
+
+				var ns int64
+				if _, err = fmt.Sscanf(p.StartTimeNanos, "%d", &ns); err != nil {
+					continue
+				}
+
+				w, ok := byTime[ns]
+				if !ok {
+					w = &core.Weight{Date: time.Unix(0, ns), Source: dataSourceName}
+					byTime[ns] = w
+					order = append(order, ns)
+				}
+
+				switch ds.DataSourceID {
+				case weightSourceID:
+					w.Weight = p.Value[0].FpVal
+				case fatSourceID:
+					w.BodyFat = p.Value[0].FpVal * 100
+				case heightSourceID:
+					w.Height = p.Value[0].FpVal * 100
+				}
+			}
+		}
+	}
+
+	weights := make([]*core.Weight, 0, len(order))
+	for _, ns := range order {
+		if byTime[ns].Weight != 0 {
+			weights = append(weights, byTime[ns])
+		}
+	}
+
+	return weights, nil
+}
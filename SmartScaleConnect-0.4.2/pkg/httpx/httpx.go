@@ -0,0 +1,138 @@
+// Package httpx runs an *http.Request through a shared retry/backoff policy,
+// so every account backend in pkg/* gets the same transient-error handling
+// instead of each rolling its own.
+package httpx
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Policy controls how Do retries a request.
+type Policy struct {
+	// MaxAttempts is the total number of tries, including the first.
+	// 1 disables retries; zero or less makes Do use DefaultPolicy in place
+	// of the whole policy.
+	MaxAttempts int
+
+	// BaseDelay is the first backoff; it doubles on every subsequent
+	// attempt and is capped at MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// RetryOn decides whether a given attempt should be retried. Nil
+	// means DefaultRetryOn.
+	RetryOn func(res *http.Response, err error) bool
+
+	// RetryPost allows retrying a POST. Off by default, since most backends
+	// don't dedup on their end; Garmin's upload is a documented exception.
+	// A request whose body can't be replayed (req.GetBody is nil) is never
+	// retried, whether or not this is set.
+	RetryPost bool
+}
+
+// DefaultPolicy retries network errors, 429 and 5xx up to 4 attempts with
+// exponential backoff starting at 500ms, capped at 30s.
+var DefaultPolicy = Policy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// DefaultRetryOn retries network errors, 429 and 5xx responses.
+func DefaultRetryOn(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+}
+
+// Do runs req through client, retrying per policy until it succeeds, the
+// policy is exhausted, or ctx is done. A 429/503 Retry-After header, when
+// present, overrides the computed backoff. The caller must close the
+// returned response's Body, same as http.Client.Do.
+func Do(ctx context.Context, client *http.Client, req *http.Request, policy Policy) (*http.Response, error) {
+	if policy.MaxAttempts <= 0 {
+		policy = DefaultPolicy
+	}
+	retryOn := policy.RetryOn
+	if retryOn == nil {
+		retryOn = DefaultRetryOn
+	}
+
+	retryable := policy.RetryPost || req.Method != http.MethodPost
+	if req.Body != nil && req.GetBody == nil {
+		// body can't be replayed on a retry attempt
+		retryable = false
+	}
+
+	var (
+		res *http.Response
+		err error
+	)
+
+	for attempt := 1; ; attempt++ {
+		cur := req.Clone(ctx)
+		if attempt > 1 && req.GetBody != nil {
+			body, berr := req.GetBody()
+			if berr != nil {
+				return nil, berr
+			}
+			cur.Body = body
+		}
+
+		res, err = client.Do(cur)
+
+		if !retryable || attempt >= policy.MaxAttempts || !retryOn(res, err) {
+			return res, err
+		}
+
+		delay := backoff(policy.BaseDelay, policy.MaxDelay, attempt)
+		if ra := retryAfter(res); ra > 0 {
+			delay = ra
+		}
+		if res != nil {
+			res.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// backoff returns an exponential delay for the given 1-indexed attempt,
+// capped at max and jittered by up to 50% to avoid a thundering herd.
+func backoff(base, max time.Duration, attempt int) time.Duration {
+	d := base << (attempt - 1)
+	if d <= 0 || d > max {
+		d = max
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(d)/2 + 1))
+	return d/2 + jitter
+}
+
+// retryAfter parses a Retry-After header in seconds form, as sent on 429/503.
+func retryAfter(res *http.Response) time.Duration {
+	if res == nil {
+		return 0
+	}
+
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+
+	secs, err := strconv.Atoi(v)
+	if err != nil || secs < 0 {
+		return 0
+	}
+
+	return time.Duration(secs) * time.Second
+}
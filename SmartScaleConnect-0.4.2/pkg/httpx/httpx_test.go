@@ -0,0 +1,112 @@
+package httpx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoHonorsRetryAfter(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	res, err := Do(context.Background(), srv.Client(), req, Policy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.EqualValues(t, 2, atomic.LoadInt32(&calls))
+	// the 1s Retry-After outweighs the policy's 10ms MaxDelay backoff
+	require.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func TestDoCancelsMidRetry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	_, err = Do(ctx, srv.Client(), req, Policy{
+		MaxAttempts: 10,
+		BaseDelay:   50 * time.Millisecond,
+		MaxDelay:    time.Second,
+	})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestDoDoesNotRetryNonIdempotentPost(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL, &onceReader{body: []byte("data")})
+	require.NoError(t, err)
+
+	res, err := Do(context.Background(), srv.Client(), req, Policy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
+
+func TestBackoffBounds(t *testing.T) {
+	base := 100 * time.Millisecond
+	max := time.Second
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		d := backoff(base, max, attempt)
+		require.GreaterOrEqual(t, d, time.Duration(0))
+		require.LessOrEqual(t, d, max)
+	}
+}
+
+// onceReader is an io.Reader with no GetBody support, simulating a body
+// that can't be replayed (e.g. a multipart upload built from a one-shot
+// io.Pipe), so Do must not retry it.
+type onceReader struct {
+	body []byte
+	read bool
+}
+
+func (r *onceReader) Read(p []byte) (int, error) {
+	if r.read {
+		return 0, io.EOF
+	}
+	r.read = true
+	return copy(p, r.body), io.EOF
+}
@@ -1,21 +1,24 @@
 package picooc
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/AlexxIT/SmartScaleConnect/pkg/httpx"
 	"github.com/google/uuid"
 )
 
 const api = "https://api2.picooc-int.com/v1/api/"
 
-func (c *Client) Login(username, password string) error {
+func (c *Client) Login(ctx context.Context, username, password string) error {
 	form := c.values("user_login_new")
 
 	var req1 struct {
@@ -55,9 +58,13 @@ func (c *Client) Login(username, password string) error {
 
 	form.Set("reqData", string(data))
 
-	res, err := c.client.Post(
-		api+"account/login", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()),
-	)
+	req, err := http.NewRequestWithContext(ctx, "POST", api+"account/login", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
 	if err != nil {
 		return err
 	}
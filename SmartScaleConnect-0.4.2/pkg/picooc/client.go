@@ -1,6 +1,7 @@
 package picooc
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+	"github.com/AlexxIT/SmartScaleConnect/pkg/httpx"
 )
 
 type Client struct {
@@ -25,11 +27,11 @@ func NewClient() *Client {
 	}
 }
 
-func (c *Client) GetAllWeights() ([]*core.Weight, error) {
-	return c.GetFilterWeights("")
+func (c *Client) GetAllWeights(ctx context.Context) ([]*core.Weight, error) {
+	return c.GetFilterWeights(ctx, "")
 }
 
-func (c *Client) GetFilterWeights(name string) ([]*core.Weight, error) {
+func (c *Client) GetFilterWeights(ctx context.Context, name string) ([]*core.Weight, error) {
 	roleID, ok := c.roleIDs[name]
 	if !ok {
 		return nil, errors.New("picooc: unknown user: " + name)
@@ -45,7 +47,11 @@ func (c *Client) GetFilterWeights(name string) ([]*core.Weight, error) {
 	params.Set("roleId", roleID)
 
 	for {
-		res, err := c.client.Get(api + "bodyIndex/bodyIndexList?" + params.Encode())
+		req, err := http.NewRequestWithContext(ctx, "GET", api+"bodyIndex/bodyIndexList?"+params.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
 		if err != nil {
 			return nil, err
 		}
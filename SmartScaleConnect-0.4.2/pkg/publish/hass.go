@@ -0,0 +1,84 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+	"github.com/AlexxIT/SmartScaleConnect/pkg/httpx"
+)
+
+// Hass pushes each metric of a weigh-in straight to Home Assistant's native
+// REST "set state" endpoint (POST /api/states/<entity_id>), one request per
+// metric, as sensor.<User>_<metric> (spaces in User become underscores), or
+// sensor.scale_<metric> when the weigh-in has no User. Unlike the MQTT publisher, the state
+// API creates/updates the entity directly - there's no separate discovery
+// step.
+type Hass struct {
+	URL   string // e.g. "http://hass:8123"
+	Token string // long-lived access token
+
+	client *http.Client
+}
+
+// NewHass returns a Hass publisher for the given base URL and long-lived
+// access token.
+func NewHass(url, token string) *Hass {
+	return &Hass{URL: url, Token: token, client: &http.Client{Timeout: time.Minute}}
+}
+
+func (p *Hass) Publish(ctx context.Context, weight *core.Weight) error {
+	entityPrefix := "sensor." + strings.ReplaceAll(weight.User, " ", "_")
+	if weight.User == "" {
+		entityPrefix = "sensor.scale"
+	}
+
+	for _, m := range metrics {
+		value, ok := m.value(weight)
+		if !ok {
+			continue
+		}
+
+		body := struct {
+			State      string `json:"state"`
+			Attributes struct {
+				UnitOfMeasurement string `json:"unit_of_measurement,omitempty"`
+				FriendlyName      string `json:"friendly_name"`
+			} `json:"attributes"`
+		}{
+			State: fmt.Sprintf("%v", value),
+		}
+		body.Attributes.UnitOfMeasurement = m.unit
+		body.Attributes.FriendlyName = entityPrefix + " " + m.name
+
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		entityID := entityPrefix + "_" + m.name
+		req, err := http.NewRequestWithContext(ctx, "POST", p.URL+"/api/states/"+entityID, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := httpx.Do(ctx, p.client, req, httpx.DefaultPolicy)
+		if err != nil {
+			return fmt.Errorf("publish: hass %s: %w", entityID, err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+			return fmt.Errorf("publish: hass %s failed: %s", entityID, res.Status)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package publish
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+	"github.com/google/uuid"
+)
+
+// MQTT publishes each metric of a weigh-in to its own topic under Topic,
+// e.g. Topic "scale/alex" publishes Weight to "scale/alex/weight". It opens
+// a fresh connection per Publish call (this tool runs one sync cycle at a
+// time, not a long-lived daemon) and speaks just enough MQTT 3.1.1 - CONNECT
+// plus PUBLISH at QoS 0/1 - to avoid pulling in a full client library for
+// that.
+type MQTT struct {
+	// Broker is a tcp://host:port address. MQTT has no TLS scheme handled
+	// here; point Broker at a local stunnel/proxy for brokers that require it.
+	Broker string
+	Topic  string // topic prefix, e.g. "scale/alex"
+
+	// QoS is 0 (fire-and-forget) or 1 (wait for PUBACK). NewMQTT sets 1;
+	// the zero value is 0.
+	QoS byte
+
+	// Retain keeps each metric's last value available to subscribers that
+	// connect later, the usual setting for a "current state" sensor.
+	// Defaults to true.
+	Retain *bool
+
+	// Discovery publishes a Home Assistant MQTT discovery config for each
+	// metric sent during this publisher's first Publish call, so sensors
+	// appear without manual YAML. A metric first carried by a later Publish
+	// call gets no discovery config. Defaults to true.
+	Discovery *bool
+
+	discovered bool
+}
+
+// NewMQTT returns an MQTT publisher with repo defaults: QoS 1, retained,
+// discovery on.
+func NewMQTT(broker, topic string) *MQTT {
+	return &MQTT{Broker: broker, Topic: topic, QoS: 1}
+}
+
+func (p *MQTT) retain() bool {
+	return p.Retain == nil || *p.Retain
+}
+
+func (p *MQTT) discovery() bool {
+	return p.Discovery == nil || *p.Discovery
+}
+
+func (p *MQTT) Publish(ctx context.Context, weight *core.Weight) error {
+	addr := strings.TrimPrefix(p.Broker, "tcp://")
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("publish: mqtt dial: %w", err)
+	}
+	defer conn.Close()
+
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+
+	if err = mqttConnect(conn, "scaleconnect-"+uuid.NewString()); err != nil {
+		return fmt.Errorf("publish: mqtt connect: %w", err)
+	}
+
+	nodeID := strings.ReplaceAll(p.Topic, "/", "_")
+	var packetID uint16 = 1
+
+	for _, m := range metrics {
+		value, ok := m.value(weight)
+		if !ok {
+			continue
+		}
+
+		topic := p.Topic + "/" + m.name
+
+		if !p.discovered && p.discovery() {
+			cfg, err := discoveryPayload(nodeID, topic, m)
+			if err != nil {
+				return fmt.Errorf("publish: mqtt discovery payload: %w", err)
+			}
+			if err = mqttPublish(conn, p.QoS, true, discoveryTopic(nodeID, m.name), cfg, &packetID); err != nil {
+				return fmt.Errorf("publish: mqtt discovery: %w", err)
+			}
+		}
+
+		payload := []byte(fmt.Sprintf("%v", value))
+		if err = mqttPublish(conn, p.QoS, p.retain(), topic, payload, &packetID); err != nil {
+			return fmt.Errorf("publish: mqtt publish %s: %w", topic, err)
+		}
+	}
+
+	p.discovered = true
+
+	return mqttDisconnect(conn)
+}
+
+// discoveryTopic is Home Assistant's well-known MQTT discovery prefix, see
+// https://www.home-assistant.io/integrations/mqtt/#discovery-topic.
+func discoveryTopic(nodeID, name string) string {
+	return "homeassistant/sensor/" + nodeID + "/" + name + "/config"
+}
+
+func discoveryPayload(nodeID, stateTopic string, m metric) ([]byte, error) {
+	cfg := struct {
+		Name              string `json:"name"`
+		StateTopic        string `json:"state_topic"`
+		UnitOfMeasurement string `json:"unit_of_measurement,omitempty"`
+		UniqueID          string `json:"unique_id"`
+		Device            struct {
+			Identifiers []string `json:"identifiers"`
+			Name        string   `json:"name"`
+		} `json:"device"`
+	}{
+		Name:              nodeID + " " + m.name,
+		StateTopic:        stateTopic,
+		UnitOfMeasurement: m.unit,
+		UniqueID:          nodeID + "_" + m.name,
+	}
+	cfg.Device.Identifiers = []string{nodeID}
+	cfg.Device.Name = nodeID
+
+	return json.Marshal(cfg)
+}
+
+func mqttConnect(conn net.Conn, clientID string) error {
+	var payload []byte
+	payload = append(payload, mqttString("MQTT")...)
+	payload = append(payload, 4)    // protocol level 4 (3.1.1)
+	payload = append(payload, 0x02) // connect flags: clean session
+	payload = binary.BigEndian.AppendUint16(payload, 30)
+	payload = append(payload, mqttString(clientID)...)
+
+	if err := mqttWritePacket(conn, 0x10, payload); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[0] != 0x20 {
+		return fmt.Errorf("unexpected packet type 0x%x", header[0])
+	}
+	if header[3] != 0 {
+		return fmt.Errorf("broker rejected connection, return code %d", header[3])
+	}
+
+	return nil
+}
+
+func mqttPublish(conn net.Conn, qos byte, retain bool, topic string, payload []byte, packetID *uint16) error {
+	var body []byte
+	body = append(body, mqttString(topic)...)
+
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, *packetID)
+	}
+	body = append(body, payload...)
+
+	flags := byte(0x30) | (qos << 1)
+	if retain {
+		flags |= 0x01
+	}
+
+	if err := mqttWritePacket(conn, flags, body); err != nil {
+		return err
+	}
+
+	if qos == 0 {
+		return nil
+	}
+
+	sent := *packetID
+	*packetID++
+
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		return err
+	}
+	if ack[0] != 0x40 {
+		return fmt.Errorf("unexpected packet type 0x%x, want PUBACK", ack[0])
+	}
+	if got := binary.BigEndian.Uint16(ack[2:4]); got != sent {
+		return errors.New("PUBACK packet id mismatch")
+	}
+
+	return nil
+}
+
+func mqttDisconnect(conn net.Conn) error {
+	_, err := conn.Write([]byte{0xE0, 0x00})
+	return err
+}
+
+// mqttWritePacket writes a fixed header (packetType plus the MQTT varint
+// remaining-length encoding) followed by body.
+func mqttWritePacket(conn net.Conn, packetType byte, body []byte) error {
+	header := []byte{packetType}
+	header = append(header, mqttRemainingLength(len(body))...)
+
+	if _, err := conn.Write(header); err != nil {
+		return err
+	}
+	_, err := conn.Write(body)
+	return err
+}
+
+// mqttString is an MQTT "UTF-8 encoded string": a 2-byte big-endian length
+// prefix followed by the bytes.
+func mqttString(s string) []byte {
+	b := binary.BigEndian.AppendUint16(nil, uint16(len(s)))
+	return append(b, s...)
+}
+
+// mqttRemainingLength encodes n per the MQTT spec's variable-length
+// quantity: 7 bits per byte, high bit set on every byte but the last.
+func mqttRemainingLength(n int) []byte {
+	var out []byte
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			break
+		}
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+// Package publish broadcasts a newly observed weigh-in to home-automation
+// systems in real time, as an alternative to posting it to a one-shot HTTP
+// endpoint (see internal.SetWeights' "json/latest" target).
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+)
+
+// Publisher emits a single weigh-in to a home-automation system.
+type Publisher interface {
+	Publish(ctx context.Context, weight *core.Weight) error
+}
+
+// metric is one scalar reading broken out of a core.Weight, so MQTT/HASS
+// publishers can send it as its own topic/sensor instead of one opaque blob.
+type metric struct {
+	name  string // e.g. "weight", "bmi" - used verbatim in topics/entity IDs
+	unit  string // Home Assistant unit_of_measurement
+	value func(w *core.Weight) (float32, bool)
+}
+
+// metrics is the set of core.Weight fields published individually. Segmental
+// and Assessment aren't included: they're per-vendor extras without a stable
+// single-value shape a generic sensor topic can represent.
+var metrics = []metric{
+	{"weight", "kg", func(w *core.Weight) (float32, bool) { return w.Weight, w.Weight != 0 }},
+	{"bmi", "", func(w *core.Weight) (float32, bool) { return w.BMI, w.BMI != 0 }},
+	{"body_fat", "%", func(w *core.Weight) (float32, bool) { return w.BodyFat, w.BodyFat != 0 }},
+	{"body_water", "%", func(w *core.Weight) (float32, bool) { return w.BodyWater, w.BodyWater != 0 }},
+	{"bone_mass", "kg", func(w *core.Weight) (float32, bool) { return w.BoneMass, w.BoneMass != 0 }},
+	{"muscle_mass", "kg", func(w *core.Weight) (float32, bool) { return w.MuscleMass, w.MuscleMass != 0 }},
+	{"visceral_fat", "", func(w *core.Weight) (float32, bool) { return float32(w.VisceralFat), w.VisceralFat != 0 }},
+	{"basal_metabolism", "kcal", func(w *core.Weight) (float32, bool) { return float32(w.BasalMetabolism), w.BasalMetabolism != 0 }},
+	{"body_score", "", func(w *core.Weight) (float32, bool) { return float32(w.BodyScore), w.BodyScore != 0 }},
+	{"heart_rate", "bpm", func(w *core.Weight) (float32, bool) { return float32(w.HeartRate), w.HeartRate != 0 }},
+}
+
+// stateFile persists the last weight.Date published per target, the same
+// convention internal.LoadToken/SaveToken use for scaleconnect.json.
+const stateFile = "scaleconnect-publish.json"
+
+var lastSent map[string]int64
+
+func loadState() map[string]int64 {
+	if lastSent != nil {
+		return lastSent
+	}
+
+	lastSent = map[string]int64{}
+
+	if f, err := os.Open(stateFile); err == nil {
+		defer f.Close()
+		_ = json.NewDecoder(f).Decode(&lastSent)
+	}
+
+	return lastSent
+}
+
+func saveState() {
+	f, err := os.Create(stateFile)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	_ = json.NewEncoder(f).Encode(lastSent)
+}
+
+// IsNew reports whether weight is newer than the last weight published to
+// target, so a re-run of the same config doesn't re-announce old readings.
+func IsNew(target string, weight *core.Weight) bool {
+	return weight.Date.Unix() > loadState()[target]
+}
+
+// MarkSent records weight as the latest published to target.
+func MarkSent(target string, weight *core.Weight) {
+	state := loadState()
+	if ts := weight.Date.Unix(); ts > state[target] {
+		state[target] = ts
+		saveState()
+	}
+}
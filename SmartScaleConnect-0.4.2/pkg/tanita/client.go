@@ -1,6 +1,7 @@
 package tanita
 
 import (
+	"context"
 	"encoding/csv"
 	"errors"
 	"fmt"
@@ -13,6 +14,7 @@ import (
 	"time"
 
 	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+	"github.com/AlexxIT/SmartScaleConnect/pkg/httpx"
 )
 
 type Client struct {
@@ -26,8 +28,12 @@ func NewClient() *Client {
 	}
 }
 
-func (c *Client) Login(username, password string) error {
-	res, err := c.client.Get("https://mytanita.eu/en/user/login")
+func (c *Client) Login(ctx context.Context, username, password string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://mytanita.eu/en/user/login", nil)
+	if err != nil {
+		return err
+	}
+	res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
 	if err != nil {
 		return err
 	}
@@ -44,9 +50,13 @@ func (c *Client) Login(username, password string) error {
 		"mail=%s&password=%s&token=%s&login=Login",
 		url.QueryEscape(username), url.QueryEscape(password), token,
 	)
-	res, err = c.client.Post(
-		"https://mytanita.eu/en/user/processlogin", "application/x-www-form-urlencoded", strings.NewReader(form),
-	)
+	req, err = http.NewRequestWithContext(ctx, "POST", "https://mytanita.eu/en/user/processlogin", strings.NewReader(form))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err = httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
 	if err != nil {
 		return err
 	}
@@ -65,9 +75,14 @@ func (c *Client) Login(username, password string) error {
 	return nil
 }
 
-func (c *Client) GetAllWeights() ([]*core.Weight, error) {
-	// VERY long operation
-	res, err := c.client.Get("https://mytanita.eu/en/user/export-csv")
+func (c *Client) GetAllWeights(ctx context.Context) ([]*core.Weight, error) {
+	// VERY long operation, so the caller's ctx deadline matters more here
+	// than anywhere else in this package.
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://mytanita.eu/en/user/export-csv", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
 	if err != nil {
 		return nil, err
 	}
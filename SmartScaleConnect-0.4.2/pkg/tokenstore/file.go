@@ -0,0 +1,91 @@
+package tokenstore
+
+import (
+	"encoding/base64"
+	"os"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileStore is the default Store: a single YAML file on disk, with one
+// base64 entry per "service/user" key.
+type FileStore struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]string // "service/user" -> base64(blob)
+}
+
+// NewFileStore returns a FileStore backed by path, creating it on first
+// Set if it doesn't exist yet.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) Get(service, user string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureLoaded(); err != nil {
+		return nil, err
+	}
+
+	encoded, ok := s.entries[key(service, user)]
+	if !ok {
+		return nil, nil
+	}
+
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+func (s *FileStore) Set(service, user string, blob []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureLoaded(); err != nil {
+		return err
+	}
+
+	s.entries[key(service, user)] = base64.StdEncoding.EncodeToString(blob)
+
+	return s.persist()
+}
+
+func (s *FileStore) ensureLoaded() error {
+	if s.entries != nil {
+		return nil
+	}
+
+	s.entries = map[string]string{}
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return yaml.Unmarshal(data, &s.entries)
+}
+
+// persist writes entries via a temp file + os.Rename, so a crash mid-write
+// can't leave the store truncated and wipe every account's token at once.
+func (s *FileStore) persist() error {
+	data, err := yaml.Marshal(s.entries)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+
+	if err = os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
+
+func key(service, user string) string {
+	return service + "/" + user
+}
@@ -0,0 +1,12 @@
+// Package tokenstore lets a provider client (garmin.Client, fitbit.Client)
+// persist its own rotated token the moment it refreshes, instead of the
+// caller having to serialize Token() back to disk by hand after every sync.
+package tokenstore
+
+// Store persists an opaque token blob per (service, user) - e.g.
+// ("garmin", "alex@example.com") - so a single dashboard instance can track
+// several accounts on the same provider without their tokens colliding.
+type Store interface {
+	Get(service, user string) ([]byte, error)
+	Set(service, user string, blob []byte) error
+}
@@ -0,0 +1,35 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"net/http"
+)
+
+// FitbitVerifier checks the X-Fitbit-Signature header Fitbit sends with
+// subscription notifications: HMAC-SHA1 of the raw request body, keyed by
+// "<clientSecret>&", base64-encoded (not URL-escaped).
+type FitbitVerifier struct {
+	ClientSecret string
+}
+
+func (v FitbitVerifier) Verify(body []byte, header http.Header) bool {
+	got, err := base64.StdEncoding.DecodeString(header.Get("X-Fitbit-Signature"))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha1.New, []byte(v.ClientSecret+"&"))
+	mac.Write(body)
+	want := mac.Sum(nil)
+
+	if !hmac.Equal(got, want) {
+		log.Printf("webhook: fitbit signature mismatch: got %s, want %s\n", hex.EncodeToString(got), hex.EncodeToString(want))
+		return false
+	}
+
+	return true
+}
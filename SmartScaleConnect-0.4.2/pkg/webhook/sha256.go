@@ -0,0 +1,31 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// SHA256Verifier checks a generic "X-Signature: sha256=<hex>" header: HMAC-
+// SHA256 of the raw request body, keyed by Secret, hex-encoded.
+type SHA256Verifier struct {
+	Secret string
+}
+
+func (v SHA256Verifier) Verify(body []byte, header http.Header) bool {
+	sig := header.Get("X-Signature")
+	sig = strings.TrimPrefix(sig, "sha256=")
+
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(v.Secret))
+	mac.Write(body)
+	want := mac.Sum(nil)
+
+	return hmac.Equal(got, want)
+}
@@ -0,0 +1,232 @@
+// Package webhook turns a push notification from a scale/app vendor into the
+// same []*core.Weight shape the polling GetAllWeights methods produce, so a
+// Subscriber can feed weigh-ins into the existing output sinks without the
+// caller having to poll.
+package webhook
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+)
+
+// Verifier authenticates an inbound webhook request before its body is
+// trusted. Implementations are per vendor (Fitbit, Xiaomi, Withings, ...).
+type Verifier interface {
+	Verify(body []byte, header http.Header) bool
+}
+
+// DefaultMaxBodySize is the request body limit applied when
+// Subscriber.MaxBodySize is left at zero.
+const DefaultMaxBodySize = 6 << 20 // 6 MiB
+
+// DefaultTimestampWindow is the replay-protection window applied when
+// Subscriber.TimestampWindow is left at zero.
+const DefaultTimestampWindow = 5 * time.Minute
+
+// DefaultQueueSize is the pending-job limit applied when Subscriber.Async is
+// set and QueueSize is left at zero.
+const DefaultQueueSize = 64
+
+// Subscriber is an http.Handler that verifies, parses, and dispatches
+// incoming weigh-in notifications.
+type Subscriber struct {
+	// Verifier checks every POST body before it's trusted. Required.
+	Verifier Verifier
+
+	// Decode turns a verified body into weigh-ins. ctx is the inbound
+	// request's context, so an account-backed Decode (see
+	// core.Subscribable.HandleNotification) can cancel its own upstream
+	// calls the same way OnWeights does. Required.
+	Decode func(ctx context.Context, body []byte) ([]*core.Weight, error)
+
+	// VerifyToken, when set, answers the GET verification handshake some
+	// vendors (Fitbit included) send when a webhook is first registered: the
+	// request carries a "verify" query value that is answered with an empty
+	// 204 if it matches, or 404 otherwise.
+	VerifyToken string
+
+	// MaxBodySize rejects larger request bodies with 413 before reading
+	// them. Zero means DefaultMaxBodySize.
+	MaxBodySize int64
+
+	// TimestampWindow rejects requests whose X-Timestamp header (unix
+	// seconds) is further than this from now, in either direction, as a
+	// replay guard. Zero means DefaultTimestampWindow. Requests without the
+	// header are only rejected if RequireTimestamp is set.
+	TimestampWindow time.Duration
+
+	// RequireTimestamp rejects requests missing X-Timestamp instead of
+	// letting them through unchecked.
+	RequireTimestamp bool
+
+	// OnWeights is called with every weigh-in parsed from a verified POST.
+	// ctx is the inbound request's context, so a slow downstream target
+	// (e.g. SetWeights against a scale backend) gets canceled if the client
+	// disconnects - unless Async is set, in which case ctx is always
+	// context.Background, since the request has already been responded to
+	// by the time a queued job runs.
+	OnWeights func(ctx context.Context, weights []*core.Weight) error
+
+	// Async, when set, queues each verified body onto a background worker
+	// instead of calling Decode/OnWeights inline, so ServeHTTP can
+	// acknowledge the webhook right away instead of blocking on a slow
+	// incremental pull (e.g. Fitbit's subscription callback, whose Decode
+	// re-fetches a day's logs per notification via
+	// core.Subscribable.HandleNotification). Jobs run in arrival order, one
+	// at a time. QueueSize bounds how many verified bodies can be pending
+	// before ServeHTTP sheds load with 503; zero means DefaultQueueSize.
+	Async     bool
+	QueueSize int
+
+	queue      chan []byte
+	startQueue sync.Once
+}
+
+func (s *Subscriber) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		// 404 here is deliberate, per Fitbit's subscriber-verification spec:
+		// an unrecognized verify code means "try the next one", which Fitbit
+		// keys off a 404 specifically. This is a different failure than a
+		// POST's bad signature below (401, see Verifier) - the two aren't in
+		// tension, they're different handshakes.
+		if s.VerifyToken != "" && r.URL.Query().Get("verify") == s.VerifyToken {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.NotFound(w, r)
+
+	case http.MethodPost:
+		maxSize := s.MaxBodySize
+		if maxSize == 0 {
+			maxSize = DefaultMaxBodySize
+		}
+		if r.ContentLength > maxSize {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if !s.checkTimestamp(r) {
+			http.Error(w, "stale or missing timestamp", http.StatusUnauthorized)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxSize+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if int64(len(body)) > maxSize {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if s.Verifier == nil || !s.Verifier.Verify(body, r.Header) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		if s.Async {
+			select {
+			case s.worker() <- body:
+			default:
+				http.Error(w, "queue full", http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		weights, err := s.Decode(r.Context(), body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if s.OnWeights != nil {
+			if err = s.OnWeights(r.Context(), weights); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// worker lazily starts s's background queue and returns it. Safe for
+// concurrent callers; the queue and its drain goroutine are only ever
+// created once.
+func (s *Subscriber) worker() chan []byte {
+	s.startQueue.Do(func() {
+		size := s.QueueSize
+		if size == 0 {
+			size = DefaultQueueSize
+		}
+		s.queue = make(chan []byte, size)
+		go s.drainQueue()
+	})
+	return s.queue
+}
+
+// drainQueue runs Decode/OnWeights for each queued body in arrival order,
+// off the HTTP goroutine that enqueued it.
+func (s *Subscriber) drainQueue() {
+	for body := range s.queue {
+		s.dispatch(body)
+	}
+}
+
+// dispatch runs one queued job. It always uses a fresh background context,
+// since the request that enqueued the job has already been responded to by
+// the time this runs.
+func (s *Subscriber) dispatch(body []byte) {
+	ctx := context.Background()
+
+	weights, err := s.Decode(ctx, body)
+	if err != nil {
+		log.Printf("webhook: async decode failed: %v\n", err)
+		return
+	}
+
+	if s.OnWeights != nil {
+		if err = s.OnWeights(ctx, weights); err != nil {
+			log.Printf("webhook: async dispatch failed: %v\n", err)
+		}
+	}
+}
+
+func (s *Subscriber) checkTimestamp(r *http.Request) bool {
+	window := s.TimestampWindow
+	if window == 0 {
+		window = DefaultTimestampWindow
+	}
+
+	header := r.Header.Get("X-Timestamp")
+	if header == "" {
+		return !s.RequireTimestamp
+	}
+
+	sec, err := strconv.ParseInt(header, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	age := time.Since(time.Unix(sec, 0))
+	if age < 0 {
+		age = -age
+	}
+
+	return age <= window
+}
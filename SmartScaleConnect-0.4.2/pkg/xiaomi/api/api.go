@@ -0,0 +1,192 @@
+// Package api adds a typed device-and-measurement layer on top of
+// xiaomi.Client.Request, for callers that want strong types instead of
+// hand-rolled params/JSON for every scale endpoint.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+	"github.com/AlexxIT/SmartScaleConnect/pkg/xiaomi"
+)
+
+// Device is a family member sharing this account's scale data.
+type Device struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// ListDevices returns the family members registered on the account. This
+// only works for the CN region, same as the underlying eco/scale proxy call.
+func ListDevices(ctx context.Context, c *xiaomi.Client) ([]Device, error) {
+	data, err := c.Request(ctx, xiaomi.MiFitnessURL(""), "/app/v1/eco/api_proxy", `{"eco_api":"eco/scale/account/list"}`, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err = xiaomi.ReadProxyResponse(data); err != nil {
+		return nil, err
+	}
+
+	var items []struct {
+		AccountId string `json:"accountId"`
+		Name      string `json:"name"`
+	}
+	if err = json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+
+	devices := make([]Device, 0, len(items))
+	for _, v := range items {
+		id, _ := strconv.ParseInt(v.AccountId, 10, 64)
+		devices = append(devices, Device{ID: id, Name: v.Name})
+	}
+
+	return devices, nil
+}
+
+// Options controls unit conversion applied by GetWeightRecords and
+// GetBodyComposition; the zero value keeps the metric units Xiaomi returns.
+type Options struct {
+	Pounds bool // report Weight/BoneMass/MuscleMass in lb instead of kg
+	Inches bool // report Height in inches instead of cm
+	TZ     *time.Location
+}
+
+const kgToLb = 2.2046226218
+
+func (o Options) convert(w *core.Weight) *core.Weight {
+	w2 := *w
+
+	if o.TZ != nil {
+		w2.Date = w2.Date.In(o.TZ)
+	}
+	if o.Pounds {
+		w2.Weight *= kgToLb
+		w2.BoneMass *= kgToLb
+		w2.MuscleMass *= kgToLb
+	}
+	if o.Inches && w2.Height != 0 {
+		w2.Height /= 2.54
+	}
+
+	return &w2
+}
+
+// GetWeightRecords returns weigh-ins within [from, to), in chronological order.
+func GetWeightRecords(ctx context.Context, c *xiaomi.Client, region string, from, to time.Time, opts Options) ([]*core.Weight, error) {
+	weights, err := c.GetFilterWeights(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*core.Weight
+	for _, w := range weights {
+		if w.Date.Before(from) || !w.Date.Before(to) {
+			continue
+		}
+		out = append(out, opts.convert(w))
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Date.Before(out[j].Date)
+	})
+
+	return out, nil
+}
+
+// BodyComposition is the subset of a weigh-in that describes body makeup
+// rather than the single weight reading itself.
+type BodyComposition struct {
+	Date      time.Time
+	BMI       float32
+	BodyFat   float32
+	BodyWater float32
+	BoneMass  float32
+
+	MuscleMass         float32
+	SkeletalMuscleMass float32
+	ProteinMass        float32
+	VisceralFat        int
+	BasalMetabolism    int
+}
+
+// GetBodyComposition returns the body-composition fields of every weigh-in
+// within [from, to).
+func GetBodyComposition(ctx context.Context, c *xiaomi.Client, region string, from, to time.Time, opts Options) ([]BodyComposition, error) {
+	weights, err := GetWeightRecords(ctx, c, region, from, to, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]BodyComposition, len(weights))
+	for i, w := range weights {
+		out[i] = BodyComposition{
+			Date:      w.Date,
+			BMI:       w.BMI,
+			BodyFat:   w.BodyFat,
+			BodyWater: w.BodyWater,
+			BoneMass:  w.BoneMass,
+
+			MuscleMass:         w.MuscleMass,
+			SkeletalMuscleMass: w.SkeletalMuscleMass,
+			ProteinMass:        w.ProteinMass,
+			VisceralFat:        w.VisceralFat,
+			BasalMetabolism:    w.BasalMetabolism,
+		}
+	}
+
+	return out, nil
+}
+
+// Subscribe polls every interval for weigh-ins newer than the last one it
+// emitted, sending each on the returned weigh-in channel, until ctx is
+// canceled. Subscribe closes the weigh-in channel once ctx is canceled; poll
+// errors go to the error channel, dropped when one is already pending.
+func Subscribe(ctx context.Context, c *xiaomi.Client, region string, interval time.Duration, opts Options) (<-chan *core.Weight, <-chan error) {
+	out := make(chan *core.Weight)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(out)
+
+		cursor := time.Now()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// from is inclusive: step past cursor so the last emitted
+				// weigh-in isn't sent again
+				weights, err := GetWeightRecords(ctx, c, region, cursor.Add(time.Nanosecond), time.Now(), opts)
+				if err != nil {
+					select {
+					case errs <- err:
+					default:
+					}
+					continue
+				}
+
+				for _, w := range weights {
+					select {
+					case out <- w:
+					case <-ctx.Done():
+						return
+					}
+					if w.Date.After(cursor) {
+						cursor = w.Date
+					}
+				}
+			}
+		}
+	}()
+
+	return out, errs
+}
@@ -2,6 +2,7 @@ package xiaomi
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"crypto/rand"
 	"crypto/rc4"
@@ -20,6 +21,7 @@ import (
 	"time"
 
 	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+	"github.com/AlexxIT/SmartScaleConnect/pkg/httpx"
 )
 
 const (
@@ -27,18 +29,18 @@ const (
 	AppMiFitness  = "miothealth"
 )
 
-func (c *Client) Login(username, password string) error {
-	res1, err := c.serviceLogin()
+func (c *Client) Login(ctx context.Context, username, password string) error {
+	res1, err := c.serviceLogin(ctx)
 	if err != nil {
 		return err
 	}
 
-	res2, err := c.serviceLogin2(res1, username, password)
+	res2, err := c.serviceLogin2(ctx, res1, username, password)
 	if err != nil {
 		return err
 	}
 
-	return c.serviceLogin3(res2.Location)
+	return c.serviceLogin3(ctx, res2.Location)
 }
 
 type loginResponse1 struct {
@@ -58,8 +60,15 @@ type loginResponse1 struct {
 	//Desc           string      `json:"desc"`
 }
 
-func (c *Client) serviceLogin() (*loginResponse1, error) {
-	res, err := c.client.Get("https://account.xiaomi.com/pass/serviceLogin?_json=true&sid=" + c.sid)
+func (c *Client) serviceLogin(ctx context.Context) (*loginResponse1, error) {
+	url1 := "https://account.xiaomi.com/pass/serviceLogin?_json=true&sid=" + c.sid
+	c.debugf("GET %s", url1)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url1, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +78,8 @@ func (c *Client) serviceLogin() (*loginResponse1, error) {
 		return nil, err
 	}
 
+	c.debugf("GET %s => %s", url1, body)
+
 	var res1 loginResponse1
 	if err = json.Unmarshal(body, &res1); err != nil {
 		return nil, err
@@ -96,7 +107,7 @@ type loginResponse2 struct {
 	//Desc           string      `json:"desc"`
 }
 
-func (c *Client) serviceLogin2(res1 *loginResponse1, username, password string) (*loginResponse2, error) {
+func (c *Client) serviceLogin2(ctx context.Context, res1 *loginResponse1, username, password string) (*loginResponse2, error) {
 	hash := fmt.Sprintf("%X", md5.Sum([]byte(password)))
 
 	form := url.Values{
@@ -109,8 +120,8 @@ func (c *Client) serviceLogin2(res1 *loginResponse1, username, password string)
 		"user":     {username},
 	}
 
-	req, err := http.NewRequest(
-		"POST", "https://account.xiaomi.com/pass/serviceLoginAuth2", strings.NewReader(form.Encode()),
+	req, err := http.NewRequestWithContext(
+		ctx, "POST", "https://account.xiaomi.com/pass/serviceLoginAuth2", strings.NewReader(form.Encode()),
 	)
 	if err != nil {
 		return nil, err
@@ -119,7 +130,9 @@ func (c *Client) serviceLogin2(res1 *loginResponse1, username, password string)
 	req.Header.Set("Cookie", "deviceId="+core.RandString(16, 62))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	res, err := c.client.Do(req)
+	c.debugf("POST %s %s", req.URL, redact(form))
+
+	res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
 	if err != nil {
 		return nil, err
 	}
@@ -138,34 +151,46 @@ func (c *Client) serviceLogin2(res1 *loginResponse1, username, password string)
 	c.ssecurity = res2.Ssecurity
 	c.userID = res2.UserId
 
+	c.debugf("POST %s => passToken=<redacted> ssecurity=<redacted> userId=%d location=%s", req.URL, res2.UserId, res2.Location)
+
 	return &res2, nil
 }
 
-func (c *Client) serviceLogin3(location string) error {
-	res, err := c.client.Get(location)
+func (c *Client) serviceLogin3(ctx context.Context, location string) error {
+	c.debugf("GET %s", location)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", location, nil)
 	if err != nil {
 		return err
 	}
-	defer res.Body.Close()
 
-	for _, s := range res.Header["Set-Cookie"] {
-		s, _, _ = strings.Cut(s, ";")
-		if len(c.cookies) > 0 {
-			c.cookies += "; "
-		}
-		c.cookies += s
+	// the client's jar captures Set-Cookie from this response automatically
+	res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
+	if err != nil {
+		return err
 	}
+	defer res.Body.Close()
+
+	c.debugf("GET %s => %d", location, res.StatusCode)
 
 	return nil
 }
 
-func (c *Client) OAuth2(params, username, password string) (string, error) {
-	res1, err := c.oauth2Authorize(params)
+func (c *Client) OAuth2(ctx context.Context, params, username, password string) (string, error) {
+	cs := newCSRFState()
+	c.csrf = cs
+
+	params += "&state=" + url.QueryEscape(cs.state)
+	if !c.SkipNonceCheck {
+		params += "&nonce=" + url.QueryEscape(cs.nonceParam())
+	}
+
+	res1, err := c.oauth2Authorize(ctx, params)
 	if err != nil {
 		return "", err
 	}
 
-	res2, err := c.serviceLogin2(res1, username, password)
+	res2, err := c.serviceLogin2(ctx, res1, username, password)
 	if err != nil {
 		return "", err
 	}
@@ -182,20 +207,59 @@ func (c *Client) OAuth2(params, username, password string) (string, error) {
 		},
 	}
 
-	res, err := client.Get(res2.Location)
+	req, err := http.NewRequestWithContext(ctx, "GET", res2.Location, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := httpx.Do(ctx, client, req, httpx.DefaultPolicy)
 	if err != nil {
 		return "", err
 	}
 	defer res.Body.Close()
 
 	location := res.Header.Get("Location")
-	_, code, _ := strings.Cut(location, "=")
 
-	return code, nil
+	c.debugf("GET %s => %d", res2.Location, res.StatusCode)
+
+	return c.verifyOAuthRedirect(location)
 }
 
-func (c *Client) oauth2Authorize(params string) (*loginResponse1, error) {
-	res, err := c.client.Get("https://account.xiaomi.com/oauth2/authorize?" + params)
+// verifyOAuthRedirect checks the final redirect's state against what OAuth2
+// embedded in the authorize request, and (if an id_token is present) its
+// nonce claim, before returning the authorization code.
+func (c *Client) verifyOAuthRedirect(location string) (string, error) {
+	query := location
+	if i := strings.Index(location, "?"); i >= 0 {
+		query = location[i+1:]
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", err
+	}
+
+	if c.csrf != nil && values.Get("state") != c.csrf.state {
+		return "", errors.New("xiaomi: oauth2 state mismatch")
+	}
+
+	if idToken := values.Get("id_token"); idToken != "" && c.csrf != nil && !c.SkipNonceCheck {
+		if err = c.csrf.verifyIDToken(idToken); err != nil {
+			return "", err
+		}
+	}
+
+	return values.Get("code"), nil
+}
+
+func (c *Client) oauth2Authorize(ctx context.Context, params string) (*loginResponse1, error) {
+	url1 := "https://account.xiaomi.com/oauth2/authorize?" + params
+	c.debugf("GET %s", url1)
+
+	req1, err := http.NewRequestWithContext(ctx, "GET", url1, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := httpx.Do(ctx, c.client, req1, httpx.DefaultPolicy)
 	if err != nil {
 		return nil, err
 	}
@@ -205,6 +269,8 @@ func (c *Client) oauth2Authorize(params string) (*loginResponse1, error) {
 		return nil, err
 	}
 
+	c.debugf("GET %s => %s", url1, body)
+
 	var json1 struct {
 		Data struct {
 			OauthLoginUrl string `json:"oauthLoginUrl"`
@@ -215,7 +281,13 @@ func (c *Client) oauth2Authorize(params string) (*loginResponse1, error) {
 		return nil, err
 	}
 
-	res, err = c.client.Get(json1.Data.OauthLoginUrl)
+	c.debugf("GET %s", json1.Data.OauthLoginUrl)
+
+	req2, err := http.NewRequestWithContext(ctx, "GET", json1.Data.OauthLoginUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err = httpx.Do(ctx, c.client, req2, httpx.DefaultPolicy)
 	if err != nil {
 		return nil, err
 	}
@@ -233,7 +305,51 @@ func (c *Client) oauth2Authorize(params string) (*loginResponse1, error) {
 	return &res1, nil
 }
 
-func (c *Client) Request(baseURL, apiURL, params string, headers map[string]string) ([]byte, error) {
+// unauthorizedCodes are the known res1.Code values Xiaomi returns once
+// ssecurity/cookies have expired and the request needs a fresh session.
+var unauthorizedCodes = map[int]bool{-1: true, 3: true, 401: true, 403: true}
+
+func (c *Client) Request(ctx context.Context, baseURL, apiURL, params string, headers map[string]string) ([]byte, error) {
+	data, err := c.requestOnce(ctx, baseURL, apiURL, params, headers)
+	if err == errUnauthorized {
+		if c.passToken == "" {
+			return nil, errors.New("xiaomi: session expired and no passToken to refresh")
+		}
+		if err = c.RefreshSession(ctx); err != nil {
+			return nil, fmt.Errorf("xiaomi: refresh session: %w", err)
+		}
+		data, err = c.requestOnce(ctx, baseURL, apiURL, params, headers)
+	}
+	return data, err
+}
+
+var errUnauthorized = errors.New("xiaomi: unauthorized")
+
+// syncCookies mirrors the account.xiaomi.com login cookies onto baseURL's
+// host, since the RC4-encrypted API lives on a different domain but still
+// expects them, and also remembers baseURL so SaveSession can export it.
+func (c *Client) syncCookies(baseURL string) error {
+	accountURL, err := url.Parse(accountBaseURL)
+	if err != nil {
+		return err
+	}
+
+	apiURL, err := url.Parse(baseURL)
+	if err != nil {
+		return err
+	}
+
+	c.jar.SetCookies(apiURL, c.jar.Cookies(accountURL))
+
+	if c.cookieHosts == nil {
+		c.cookieHosts = map[string]bool{}
+	}
+	c.cookieHosts[baseURL] = true
+
+	return nil
+}
+
+func (c *Client) requestOnce(ctx context.Context, baseURL, apiURL, params string, headers map[string]string) ([]byte, error) {
 	form := url.Values{"data": {params}}
 
 	nonce := GenNonce()
@@ -257,24 +373,34 @@ func (c *Client) Request(baseURL, apiURL, params string, headers map[string]stri
 	// 4. add nonce
 	form.Set("_nonce", base64.StdEncoding.EncodeToString(nonce))
 
-	req, err := http.NewRequest("POST", baseURL+apiURL, strings.NewReader(form.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+apiURL, strings.NewReader(form.Encode()))
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Cookie", c.cookies)
+	// the API host is a different domain than account.xiaomi.com, but the
+	// login cookies are still required there, so mirror them into the jar
+	if err = c.syncCookies(baseURL); err != nil {
+		return nil, err
+	}
+
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
 
-	res, err := c.client.Do(req)
+	c.debugf("POST %s %s", req.URL, params)
+
+	res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		return nil, errUnauthorized
+	}
 	if res.StatusCode != http.StatusOK {
 		return nil, errors.New(res.Status)
 	}
@@ -294,6 +420,8 @@ func (c *Client) Request(baseURL, apiURL, params string, headers map[string]stri
 		return nil, err
 	}
 
+	c.debugf("POST %s => %s", req.URL, plaintext)
+
 	var res1 struct {
 		Code    int             `json:"code"`
 		Message string          `json:"message"`
@@ -303,24 +431,37 @@ func (c *Client) Request(baseURL, apiURL, params string, headers map[string]stri
 		return nil, err
 	}
 
+	if unauthorizedCodes[res1.Code] {
+		return nil, errUnauthorized
+	}
 	if res1.Code != 0 {
+		c.warnf("POST %s failed: code=%d message=%s", req.URL, res1.Code, res1.Message)
 		return nil, errors.New("xiaomi: " + res1.Message)
 	}
 
 	return res1.Result, nil
 }
 
-func (c *Client) LoginWithToken(token string) error {
+func (c *Client) LoginWithToken(ctx context.Context, token string) error {
 	userID, passToken, _ := strings.Cut(token, ":")
 
-	req, err := http.NewRequest("GET", "https://account.xiaomi.com/pass/serviceLogin?_json=true&sid="+c.sid, nil)
+	accountURL, err := url.Parse(accountBaseURL)
 	if err != nil {
 		return err
 	}
+	c.jar.SetCookies(accountURL, []*http.Cookie{
+		{Name: "userId", Value: userID},
+		{Name: "passToken", Value: passToken},
+	})
 
-	req.Header.Set("Cookie", fmt.Sprintf("userId=%s; passToken=%s", userID, passToken))
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://account.xiaomi.com/pass/serviceLogin?_json=true&sid="+c.sid, nil)
+	if err != nil {
+		return err
+	}
 
-	res, err := c.client.Do(req)
+	c.debugf("GET %s Cookie: userId=%s passToken=<redacted>", req.URL, userID)
+
+	res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
 	if err != nil {
 		return err
 	}
@@ -339,13 +480,36 @@ func (c *Client) LoginWithToken(token string) error {
 	c.ssecurity = res2.Ssecurity
 	c.userID = res2.UserId
 
-	return c.serviceLogin3(res2.Location)
+	if err = c.serviceLogin3(ctx, res2.Location); err != nil {
+		return err
+	}
+
+	if c.onRefresh != nil {
+		c.onRefresh(c.Token())
+	}
+
+	return nil
 }
 
 func (c *Client) Token() string {
 	return fmt.Sprintf("%d:%s", c.userID, c.passToken)
 }
 
+// RefreshSession re-authenticates with the stored passToken and rotates
+// ssecurity and cookies, without prompting the caller for credentials again.
+func (c *Client) RefreshSession(ctx context.Context) error {
+	if c.passToken == "" {
+		return errors.New("xiaomi: no passToken to refresh session")
+	}
+	return c.LoginWithToken(ctx, c.Token())
+}
+
+// OnRefresh registers a callback invoked every time the session is renewed,
+// so callers can persist the rotated token to disk.
+func (c *Client) OnRefresh(fn func(newToken string)) {
+	c.onRefresh = fn
+}
+
 const loginPrefix = "&&&START&&&"
 
 func readLoginResponse(res *http.Response) ([]byte, error) {
@@ -1,38 +1,78 @@
 package xiaomi
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/cookiejar"
 	"strconv"
 	"time"
 
 	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+	"golang.org/x/net/publicsuffix"
 )
 
+// accountBaseURL is the login domain whose cookies need to be mirrored onto
+// every API host the client talks to (see syncCookies).
+const accountBaseURL = "https://account.xiaomi.com"
+
 type Client struct {
 	client *http.Client
+	jar    *cookiejar.Jar // cookies for account.xiaomi.com and the API hosts below
 
 	sid       string // for login
-	cookies   string // for auth
 	userID    int64  // for some requests
 	ssecurity []byte // for encryption
 	passToken string
+
+	// cookieHosts is every API base URL we've ever synced account.xiaomi.com
+	// cookies to, so SaveSession knows which jar entries to export.
+	cookieHosts map[string]bool
+
+	onRefresh func(newToken string)
+
+	csrf *csrfState
+
+	// SkipNonceCheck disables embedding and verifying the OIDC nonce during
+	// OAuth2, for the rare provider that doesn't echo it back.
+	SkipNonceCheck bool
+
+	// Logger, when set, traces every HTTP call this client makes, with
+	// credentials redacted. See redact() for what gets hidden.
+	Logger Logger
+}
+
+// TokenSource mirrors the OAuth2 refresh-token pattern: it lets a caller read
+// the current token, force a session renewal, and get notified whenever one
+// happens so the new value can be persisted to disk.
+type TokenSource interface {
+	Token() string
+	Refresh(ctx context.Context) error
+	OnRefresh(fn func(newToken string))
+}
+
+// Refresh renews the session via RefreshSession, satisfying TokenSource.
+func (c *Client) Refresh(ctx context.Context) error {
+	return c.RefreshSession(ctx)
 }
 
 func NewClient(app string) *Client {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
 	return &Client{
-		client: &http.Client{Timeout: time.Minute},
-		sid:    app,
+		client:      &http.Client{Timeout: time.Minute, Jar: jar},
+		jar:         jar,
+		sid:         app,
+		cookieHosts: map[string]bool{},
 	}
 }
 
-func (c *Client) GetAllWeights() ([]*core.Weight, error) {
-	return c.getAllWeights("")
+func (c *Client) GetAllWeights(ctx context.Context) ([]*core.Weight, error) {
+	return c.getAllWeights(ctx, "")
 }
 
-func (c *Client) getAllWeights(region string) ([]*core.Weight, error) {
+func (c *Client) getAllWeights(ctx context.Context, region string) ([]*core.Weight, error) {
 	var weights []*core.Weight
 
 	ts := time.Now().Add(24 * time.Hour).Unix()
@@ -40,7 +80,7 @@ func (c *Client) getAllWeights(region string) ([]*core.Weight, error) {
 
 	for {
 		// this request depends on user region
-		data, err := c.Request(MiFitnessURL(region), "/app/v1/data/get_fitness_data_by_time", params, nil)
+		data, err := c.Request(ctx, MiFitnessURL(region), "/app/v1/data/get_fitness_data_by_time", params, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -98,36 +138,36 @@ func (c *Client) getAllWeights(region string) ([]*core.Weight, error) {
 				//ScoreStandardType  int     `json:"score_standard_type"`  // S400
 				SkeletalMuscleMass float32 `json:"skeletal_muscle_mass"` // S400
 
-				//BodyShape                 int     `json:"body_shape"`                   // Eight
-				//FatMass                   float32 `json:"fat_mass"`                     // Eight
-				//LeftLowerLimbFatMass      float32 `json:"left_lower_limb_fat_mass"`     // Eight
-				//LeftLowerLimbFatRank      int     `json:"left_lower_limb_fat_rank"`     // Eight
-				//LeftLowerLimbMuscleMass   int     `json:"left_lower_limb_muscle_mass"`  // Eight
-				//LeftLowerLimbMuscleRank   int     `json:"left_lower_limb_muscle_rank"`  // Eight
-				//LeftUpperLimbFatMass      float32 `json:"left_upper_limb_fat_mass"`     // Eight
-				//LeftUpperLimbFatRank      int     `json:"left_upper_limb_fat_rank"`     // Eight
-				//LeftUpperLimbMuscleMass   float32 `json:"left_upper_limb_muscle_mass"`  // Eight
-				//LeftUpperLimbMuscleRank   int     `json:"left_upper_limb_muscle_rank"`  // Eight
-				//LimbsFatBalance           int     `json:"limbs_fat_balance"`            // Eight
-				//LimbsMuscleBalance        int     `json:"limbs_muscle_balance"`         // Eight
-				//LimbsSkeletalMuscleIndex  float32 `json:"limbs_skeletal_muscle_index"`  // Eight
-				//LowerLimbFatBalance       int     `json:"lower_limb_fat_balance"`       // Eight
-				//LowerLimbMuscleBalance    int     `json:"lower_limb_muscle_balance"`    // Eight
-				//RecommendedCaloriesIntake int     `json:"recommended_calories_intake"`  // Eight
-				//RightLowerLimbFatMass     float32 `json:"right_lower_limb_fat_mass"`    // Eight
-				//RightLowerLimbFatRank     int     `json:"right_lower_limb_fat_rank"`    // Eight
-				//RightLowerLimbMuscleMass  float32 `json:"right_lower_limb_muscle_mass"` // Eight
-				//RightLowerLimbMuscleRank  int     `json:"right_lower_limb_muscle_rank"` // Eight
-				//RightUpperLimbFatMass     float32 `json:"right_upper_limb_fat_mass"`    // Eight
-				//RightUpperLimbFatRank     int     `json:"right_upper_limb_fat_rank"`    // Eight
-				//RightUpperLimbMuscleMass  float32 `json:"right_upper_limb_muscle_mass"` // Eight
-				//RightUpperLimbMuscleRank  int     `json:"right_upper_limb_muscle_rank"` // Eight
-				//TrunkFatMass              float32 `json:"trunk_fat_mass"`               // Eight
-				//TrunkFatRank              int     `json:"trunk_fat_rank"`               // Eight
-				//TrunkMuscleMass           float32 `json:"trunk_muscle_mass"`            // Eight
-				//TrunkMuscleRank           int     `json:"trunk_muscle_rank"`            // Eight
-				//UpperLimbFatBalance       int     `json:"upper_limb_fat_balance"`       // Eight
-				//UpperLimbMuscleBalance    int     `json:"upper_limb_muscle_balance"`    // Eight
+				//BodyShape                 int     `json:"body_shape"`                  // Eight
+				//FatMass                   float32 `json:"fat_mass"`                    // Eight
+				LeftLowerLimbFatMass    float32 `json:"left_lower_limb_fat_mass"`    // Eight
+				LeftLowerLimbFatRank    int     `json:"left_lower_limb_fat_rank"`    // Eight
+				LeftLowerLimbMuscleMass float32 `json:"left_lower_limb_muscle_mass"` // Eight
+				LeftLowerLimbMuscleRank int     `json:"left_lower_limb_muscle_rank"` // Eight
+				LeftUpperLimbFatMass    float32 `json:"left_upper_limb_fat_mass"`    // Eight
+				LeftUpperLimbFatRank    int     `json:"left_upper_limb_fat_rank"`    // Eight
+				LeftUpperLimbMuscleMass float32 `json:"left_upper_limb_muscle_mass"` // Eight
+				LeftUpperLimbMuscleRank int     `json:"left_upper_limb_muscle_rank"` // Eight
+				//LimbsFatBalance           int     `json:"limbs_fat_balance"`           // Eight
+				//LimbsMuscleBalance        int     `json:"limbs_muscle_balance"`        // Eight
+				LimbsSkeletalMuscleIndex float32 `json:"limbs_skeletal_muscle_index"` // Eight
+				//LowerLimbFatBalance       int     `json:"lower_limb_fat_balance"`      // Eight
+				//LowerLimbMuscleBalance    int     `json:"lower_limb_muscle_balance"`   // Eight
+				//RecommendedCaloriesIntake int     `json:"recommended_calories_intake"` // Eight
+				RightLowerLimbFatMass    float32 `json:"right_lower_limb_fat_mass"`    // Eight
+				RightLowerLimbFatRank    int     `json:"right_lower_limb_fat_rank"`    // Eight
+				RightLowerLimbMuscleMass float32 `json:"right_lower_limb_muscle_mass"` // Eight
+				RightLowerLimbMuscleRank int     `json:"right_lower_limb_muscle_rank"` // Eight
+				RightUpperLimbFatMass    float32 `json:"right_upper_limb_fat_mass"`    // Eight
+				RightUpperLimbFatRank    int     `json:"right_upper_limb_fat_rank"`    // Eight
+				RightUpperLimbMuscleMass float32 `json:"right_upper_limb_muscle_mass"` // Eight
+				RightUpperLimbMuscleRank int     `json:"right_upper_limb_muscle_rank"` // Eight
+				TrunkFatMass             float32 `json:"trunk_fat_mass"`               // Eight
+				TrunkFatRank             int     `json:"trunk_fat_rank"`               // Eight
+				TrunkMuscleMass          float32 `json:"trunk_muscle_mass"`            // Eight
+				TrunkMuscleRank          int     `json:"trunk_muscle_rank"`            // Eight
+				//UpperLimbFatBalance       int     `json:"upper_limb_fat_balance"`      // Eight
+				//UpperLimbMuscleBalance    int     `json:"upper_limb_muscle_balance"`   // Eight
 			}
 
 			if err = json.Unmarshal([]byte(v1.Value), &res2); err != nil {
@@ -155,6 +195,44 @@ func (c *Client) getAllWeights(region string) ([]*core.Weight, error) {
 				Source: v1.Sid, // blt.3.xxx
 			}
 
+			// Only the "Eight" scale reports per-limb breakdown; S400 leaves
+			// these fields zero.
+			if res2.TrunkFatMass != 0 || res2.LeftLowerLimbFatMass != 0 {
+				w.Segmental = &core.Segmental{
+					LeftArm: core.LimbComposition{
+						FatMass:    res2.LeftUpperLimbFatMass,
+						FatRank:    res2.LeftUpperLimbFatRank,
+						MuscleMass: res2.LeftUpperLimbMuscleMass,
+						MuscleRank: res2.LeftUpperLimbMuscleRank,
+					},
+					RightArm: core.LimbComposition{
+						FatMass:    res2.RightUpperLimbFatMass,
+						FatRank:    res2.RightUpperLimbFatRank,
+						MuscleMass: res2.RightUpperLimbMuscleMass,
+						MuscleRank: res2.RightUpperLimbMuscleRank,
+					},
+					LeftLeg: core.LimbComposition{
+						FatMass:    res2.LeftLowerLimbFatMass,
+						FatRank:    res2.LeftLowerLimbFatRank,
+						MuscleMass: res2.LeftLowerLimbMuscleMass,
+						MuscleRank: res2.LeftLowerLimbMuscleRank,
+					},
+					RightLeg: core.LimbComposition{
+						FatMass:    res2.RightLowerLimbFatMass,
+						FatRank:    res2.RightLowerLimbFatRank,
+						MuscleMass: res2.RightLowerLimbMuscleMass,
+						MuscleRank: res2.RightLowerLimbMuscleRank,
+					},
+					Trunk: core.LimbComposition{
+						FatMass:    res2.TrunkFatMass,
+						FatRank:    res2.TrunkFatRank,
+						MuscleMass: res2.TrunkMuscleMass,
+						MuscleRank: res2.TrunkMuscleRank,
+					},
+					SkeletalMuscleIndex: res2.LimbsSkeletalMuscleIndex,
+				}
+			}
+
 			weights = append(weights, w)
 		}
 
@@ -210,10 +288,10 @@ func (c *Client) getAllWeights(region string) ([]*core.Weight, error) {
 //}
 
 // GetFilterWeights filter can be region or scale model
-func (c *Client) GetFilterWeights(filter string) ([]*core.Weight, error) {
+func (c *Client) GetFilterWeights(ctx context.Context, filter string) ([]*core.Weight, error) {
 	// check if the filter is a region
 	if s := MiFitnessURL(filter); s != "" {
-		return c.getAllWeights(filter)
+		return c.getAllWeights(ctx, filter)
 	}
 
 	var weights []*core.Weight
@@ -226,7 +304,7 @@ func (c *Client) GetFilterWeights(filter string) ([]*core.Weight, error) {
 		)
 		params = fmt.Sprintf(`{"eco_api":"eco/scale/getData","params":%q}`, params)
 		// this request works only for main (CN) region
-		data, err := c.Request(MiFitnessURL(""), "/app/v1/eco/api_proxy", params, nil)
+		data, err := c.Request(ctx, MiFitnessURL(""), "/app/v1/eco/api_proxy", params, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -243,7 +321,7 @@ func (c *Client) GetFilterWeights(filter string) ([]*core.Weight, error) {
 	return weights, nil
 }
 
-func (c *Client) GetModelWeights(region, model string) ([]*core.Weight, error) {
+func (c *Client) GetModelWeights(ctx context.Context, region, model string) ([]*core.Weight, error) {
 	var weights []*core.Weight
 
 	switch region {
@@ -256,7 +334,7 @@ func (c *Client) GetModelWeights(region, model string) ([]*core.Weight, error) {
 			)
 			// this request works only for main (CN) region
 			data, err := c.Request(
-				"https://api.io.mi.com/app", "/eco/scale/getData", params,
+				ctx, "https://api.io.mi.com/app", "/eco/scale/getData", params,
 				map[string]string{
 					"MIOT-REQUEST-MODEL": model,
 				},
@@ -278,7 +356,7 @@ func (c *Client) GetModelWeights(region, model string) ([]*core.Weight, error) {
 			)
 			// this request works only for main (CN) region
 			data, err := c.Request(
-				"https://"+region+".api.io.mi.com/app", "/eco/common/scale/getUserDataByPage", params,
+				ctx, "https://"+region+".api.io.mi.com/app", "/eco/common/scale/getUserDataByPage", params,
 				map[string]string{
 					"MIOT-REQUEST-MODEL": model,
 				},
@@ -337,6 +415,18 @@ func unmarshalScaleData(data []byte, weights *[]*core.Weight) (ts int64, err err
 				SkeletalMuscleMass float32 `json:"smm"`        // 37.6 kg
 				ReportFrom         string  `json:"reportFrom"` // Regular
 
+				// Fitness-test report, only present for scales paired with a
+				// phone camera or BP cuff.
+				Assess *struct {
+					SysBP    int                  `json:"sysBp"`    // 120 mmHg
+					DiaBP    int                  `json:"diaBp"`    // 80 mmHg
+					SpO2     int                  `json:"spo2"`     // 97 %
+					Balance  float32              `json:"balance"`  // 3.2 degrees
+					Agility  int                  `json:"agility"`  // 850 ms
+					Aerobic  int                  `json:"aerobic"`  // 320 kcal
+					Keypoint map[string][]float32 `json:"keypoint"` // {"leftShoulder":[x,y,score]}
+				} `json:"assess"`
+
 				//UserID             int     `json:"miid"`       // 1234567890
 				//Duid               int     `json:"duid"`       // 6 ?
 				//UserType           int     `json:"userType"`   // 1 ?
@@ -401,6 +491,35 @@ func unmarshalScaleData(data []byte, weights *[]*core.Weight) (ts int64, err err
 				User:   v2.User.Name,
 				Source: v2.ReportFrom,
 			}
+
+			if v2.Assess != nil {
+				a := &core.Assessment{
+					BloodPressureSys: v2.Assess.SysBP,
+					BloodPressureDia: v2.Assess.DiaBP,
+					SpO2:             v2.Assess.SpO2,
+					BalanceAngle:     v2.Assess.Balance,
+					AgilityMs:        v2.Assess.Agility,
+					AerobicKcal:      v2.Assess.Aerobic,
+				}
+				if len(v2.Assess.Keypoint) > 0 {
+					a.Keypoints = make(map[string]core.Keypoint, len(v2.Assess.Keypoint))
+					for name, xys := range v2.Assess.Keypoint {
+						var kp core.Keypoint
+						if len(xys) > 0 {
+							kp.X = xys[0]
+						}
+						if len(xys) > 1 {
+							kp.Y = xys[1]
+						}
+						if len(xys) > 2 {
+							kp.Score = xys[2]
+						}
+						a.Keypoints[name] = kp
+					}
+				}
+				w.Assessment = a
+			}
+
 			*weights = append(*weights, w)
 
 		case 2:
@@ -577,6 +696,13 @@ func MiFitnessURL(region string) string {
 //	return ""
 //}
 
+// ReadProxyResponse unwraps the result of an "eco/..." call made through the
+// api_proxy endpoint, for callers (e.g. the api subpackage) outside this
+// package that need to issue their own proxied requests via Client.Request.
+func ReadProxyResponse(data []byte) ([]byte, error) {
+	return readProxyResponse(data)
+}
+
 func readProxyResponse(data []byte) ([]byte, error) {
 	var res1 struct {
 		Resp string `json:"resp"`
@@ -0,0 +1,62 @@
+package xiaomi
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+)
+
+// csrfState guards a single OAuth2() call: state prevents the redirect from
+// being forged, nonceHash lets us bind an optional OIDC id_token to this
+// exact authorize request without keeping the raw nonce around server-side.
+type csrfState struct {
+	state     string
+	nonceHash []byte
+}
+
+func newCSRFState() *csrfState {
+	nonce := core.RandString(24, 62)
+	sum := sha256.Sum256([]byte(nonce))
+
+	return &csrfState{
+		state:     core.RandString(24, 62),
+		nonceHash: sum[:],
+	}
+}
+
+func (cs *csrfState) nonceParam() string {
+	return base64.RawURLEncoding.EncodeToString(cs.nonceHash)
+}
+
+// verifyIDToken checks that the id_token's "nonce" claim hashes to the value
+// embedded in the authorize URL, proving it was issued for this request.
+func (cs *csrfState) verifyIDToken(idToken string) error {
+	_, payload, ok := strings.Cut(idToken, ".")
+	if !ok {
+		return errors.New("xiaomi: malformed id_token")
+	}
+	payload, _, _ = strings.Cut(payload, ".")
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return err
+	}
+
+	var claims struct {
+		Nonce string `json:"nonce"`
+	}
+	if err = json.Unmarshal(data, &claims); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256([]byte(claims.Nonce))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != cs.nonceParam() {
+		return errors.New("xiaomi: id_token nonce mismatch")
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package xiaomi
+
+import (
+	"net/url"
+)
+
+// Logger is the minimal interface Client calls into for request/response
+// tracing. Leave Client.Logger nil to disable logging entirely.
+type Logger interface {
+	Debugf(format string, args ...any)
+	Warnf(format string, args ...any)
+}
+
+func (c *Client) debugf(format string, args ...any) {
+	if c.Logger != nil {
+		c.Logger.Debugf(format, args...)
+	}
+}
+
+func (c *Client) warnf(format string, args ...any) {
+	if c.Logger != nil {
+		c.Logger.Warnf(format, args...)
+	}
+}
+
+// redactedFields never get logged in full: passToken/ssecurity/hash identify
+// the account, and _nonce/signature/data are the RC4 request envelope (data
+// is opaque ciphertext anyway, but the login form's "hash" is an MD5 of the
+// plaintext password, so it's redacted the same way).
+var redactedFields = map[string]bool{
+	"passToken": true,
+	"ssecurity": true,
+	"hash":      true,
+	"password":  true,
+	"_nonce":    true,
+	"signature": true,
+	"data":      true,
+}
+
+// redact returns a copy of form with every sensitive value replaced, safe to
+// pass to Logger.
+func redact(form url.Values) url.Values {
+	out := make(url.Values, len(form))
+	for k, v := range form {
+		if redactedFields[k] {
+			out[k] = []string{"<redacted>"}
+		} else {
+			out[k] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,308 @@
+package xiaomi
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Session is the full login state needed to resume a Client without
+// re-running Login: the passToken alone (as returned by Token()) is not
+// enough, because ssecurity and the jar's login cookies are also required to
+// sign and encrypt requests.
+type Session struct {
+	UserID    int64           `json:"userID"`
+	PassToken string          `json:"passToken"`
+	Ssecurity []byte          `json:"ssecurity"`
+	Cookies   []SessionCookie `json:"cookies"`
+	Sid       string          `json:"sid"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// SessionCookie is one jar cookie, keyed by the URL it was stored under so
+// it can be restored into the right cookiejar bucket.
+type SessionCookie struct {
+	URL   string `json:"url"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SessionStore persists a Session under an opaque key, e.g. "xiaomi:user@mail.com".
+type SessionStore interface {
+	Load(key string) (*Session, error)
+	Save(key string, s *Session) error
+}
+
+// LoadSession restores the client's full login state (userID, passToken,
+// ssecurity, jar cookies) from store, avoiding a LoginWithToken roundtrip.
+func (c *Client) LoadSession(store SessionStore, key string) error {
+	s, err := store.Load(key)
+	if err != nil {
+		return err
+	}
+
+	c.sid = s.Sid
+	c.userID = s.UserID
+	c.passToken = s.PassToken
+	c.ssecurity = s.Ssecurity
+
+	byURL := map[string][]*http.Cookie{}
+	for _, sc := range s.Cookies {
+		byURL[sc.URL] = append(byURL[sc.URL], &http.Cookie{Name: sc.Name, Value: sc.Value})
+	}
+
+	if c.cookieHosts == nil {
+		c.cookieHosts = map[string]bool{}
+	}
+
+	for raw, cookies := range byURL {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		c.jar.SetCookies(u, cookies)
+		if raw != accountBaseURL {
+			c.cookieHosts[raw] = true
+		}
+	}
+
+	return nil
+}
+
+// SaveSession persists the client's current login state to store, including
+// every jar cookie known to belong to this session (account.xiaomi.com plus
+// every API host Request has synced cookies to).
+func (c *Client) SaveSession(store SessionStore, key string) error {
+	hosts := map[string]bool{accountBaseURL: true}
+	for host := range c.cookieHosts {
+		hosts[host] = true
+	}
+
+	var cookies []SessionCookie
+	for host := range hosts {
+		u, err := url.Parse(host)
+		if err != nil {
+			continue
+		}
+		for _, ck := range c.jar.Cookies(u) {
+			cookies = append(cookies, SessionCookie{URL: host, Name: ck.Name, Value: ck.Value})
+		}
+	}
+
+	return store.Save(key, &Session{
+		Sid:       c.sid,
+		UserID:    c.userID,
+		PassToken: c.passToken,
+		Ssecurity: c.ssecurity,
+		Cookies:   cookies,
+		Timestamp: time.Now(),
+	})
+}
+
+// MemoryStore keeps sessions in process memory, unencrypted - useful for
+// tests or short-lived processes that don't need to survive a restart.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: map[string]*Session{}}
+}
+
+func (m *MemoryStore) Load(key string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[key]
+	if !ok {
+		return nil, errors.New("xiaomi: no session for " + key)
+	}
+	return s, nil
+}
+
+func (m *MemoryStore) Save(key string, s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[key] = s
+	return nil
+}
+
+// FileStore persists sessions to a single file, AES-GCM encrypted with a key
+// derived from Seed so ssecurity and cookies are never left on disk in the
+// clear. MaxAge, when non-zero, rejects sessions older than that on Load.
+type FileStore struct {
+	Path   string
+	Seed   []byte
+	MaxAge time.Duration
+}
+
+func NewFileStore(path string, seed []byte) *FileStore {
+	return &FileStore{Path: path, Seed: seed}
+}
+
+func (f *FileStore) Load(key string) (*Session, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var envelopes map[string]string
+	if err = json.Unmarshal(data, &envelopes); err != nil {
+		return nil, err
+	}
+
+	envelope, ok := envelopes[key]
+	if !ok {
+		return nil, errors.New("xiaomi: no session for " + key)
+	}
+
+	plaintext, ts, err := f.open(key, envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.MaxAge > 0 && time.Since(ts) > f.MaxAge {
+		return nil, errors.New("xiaomi: session for " + key + " expired")
+	}
+
+	var s Session
+	if err = json.Unmarshal(plaintext, &s); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (f *FileStore) Save(key string, s *Session) error {
+	var envelopes map[string]string
+
+	if data, err := os.ReadFile(f.Path); err == nil {
+		_ = json.Unmarshal(data, &envelopes)
+	}
+	if envelopes == nil {
+		envelopes = map[string]string{}
+	}
+
+	plaintext, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	envelopes[key], err = f.seal(key, plaintext, s.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(envelopes)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(f.Path, data, 0600)
+}
+
+// seal builds the "value|timestamp|signature" envelope: value is the
+// AES-GCM ciphertext, timestamp is the unix time the session was saved, and
+// signature is an HMAC-SHA256 over name+value+timestamp binding the envelope
+// to the store key so one entry can't be replayed under another.
+func (f *FileStore) seal(name string, plaintext []byte, ts time.Time) (string, error) {
+	block, err := aes.NewCipher(f.key())
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	ciphertext := gcm.Seal(nonce, nonce, plaintext, nil)
+
+	value := base64.StdEncoding.EncodeToString(ciphertext)
+	timestamp := base64.StdEncoding.EncodeToString([]byte(strconv.FormatInt(ts.Unix(), 10)))
+	signature := base64.StdEncoding.EncodeToString(f.sign(name, value, timestamp))
+
+	return value + "|" + timestamp + "|" + signature, nil
+}
+
+func (f *FileStore) open(name, envelope string) (plaintext []byte, ts time.Time, err error) {
+	parts := strings.Split(envelope, "|")
+	if len(parts) != 3 {
+		return nil, ts, errors.New("xiaomi: malformed session envelope")
+	}
+	value, timestamp, signature := parts[0], parts[1], parts[2]
+
+	want, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, ts, err
+	}
+	if !hmac.Equal(want, f.sign(name, value, timestamp)) {
+		return nil, ts, errors.New("xiaomi: session signature mismatch")
+	}
+
+	tsRaw, err := base64.StdEncoding.DecodeString(timestamp)
+	if err != nil {
+		return nil, ts, err
+	}
+	unix, err := strconv.ParseInt(string(tsRaw), 10, 64)
+	if err != nil {
+		return nil, ts, err
+	}
+	ts = time.Unix(unix, 0)
+
+	ciphertext, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ts, err
+	}
+
+	block, err := aes.NewCipher(f.key())
+	if err != nil {
+		return nil, ts, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, ts, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ts, errors.New("xiaomi: session ciphertext too short")
+	}
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+
+	plaintext, err = gcm.Open(nil, nonce, ciphertext, nil)
+	return plaintext, ts, err
+}
+
+func (f *FileStore) sign(name, value, timestamp string) []byte {
+	mac := hmac.New(sha256.New, f.key())
+	mac.Write([]byte(name))
+	mac.Write([]byte(value))
+	mac.Write([]byte(timestamp))
+	return mac.Sum(nil)
+}
+
+func (f *FileStore) key() []byte {
+	sum := sha256.Sum256(f.Seed)
+	return sum[:]
+}
@@ -1,10 +1,13 @@
 package zepp
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
 
+	"github.com/AlexxIT/SmartScaleConnect/pkg/httpx"
 	"github.com/AlexxIT/SmartScaleConnect/pkg/xiaomi"
 	"github.com/google/uuid"
 )
@@ -15,9 +18,9 @@ const paramsZeppLife = "_json=true&" +
 	"redirect_uri=https://api-mifit-cn.huami.com/huami.health.loginview.do&" +
 	"response_type=code"
 
-func (c *Client) Login(username, password string) error {
+func (c *Client) Login(ctx context.Context, username, password string) error {
 	client := xiaomi.NewClient("")
-	code, err := client.OAuth2(paramsZeppLife, username, password)
+	code, err := client.OAuth2(ctx, paramsZeppLife, username, password)
 	if err != nil {
 		return err
 	}
@@ -36,9 +39,13 @@ func (c *Client) Login(username, password string) error {
 		code, uuid.NewString(),
 	)
 
-	res, err := c.client.Post(
-		"https://account.zepp.com/v2/client/login", "application/x-www-form-urlencoded", strings.NewReader(form),
-	)
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://account.zepp.com/v2/client/login", strings.NewReader(form))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
 	if err != nil {
 		return err
 	}
@@ -86,9 +93,9 @@ func (c *Client) Login(username, password string) error {
 	return nil
 }
 
-func (c *Client) LoginWithToken(token string) error {
+func (c *Client) LoginWithToken(ctx context.Context, token string) error {
 	c.userID, c.appToken, _ = strings.Cut(token, ":")
-	return c.GetFamilyMembers()
+	return c.GetFamilyMembers(ctx)
 }
 
 func (c *Client) Token() string {
@@ -2,6 +2,7 @@ package zepp
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/AlexxIT/SmartScaleConnect/pkg/core"
+	"github.com/AlexxIT/SmartScaleConnect/pkg/httpx"
 )
 
 type Client struct {
@@ -29,12 +31,12 @@ func NewClient() *Client {
 	}
 }
 
-func (c *Client) GetAllWeights() ([]*core.Weight, error) {
-	return c.GetFilterWeights("")
+func (c *Client) GetAllWeights(ctx context.Context) ([]*core.Weight, error) {
+	return c.GetFilterWeights(ctx, "")
 }
 
-func (c *Client) GetFilterWeights(name string) ([]*core.Weight, error) {
-	familyID, err := c.GetFamilyID(name)
+func (c *Client) GetFilterWeights(ctx context.Context, name string) ([]*core.Weight, error) {
+	familyID, err := c.GetFamilyID(ctx, name)
 	if err != nil {
 		return nil, err
 	}
@@ -48,14 +50,14 @@ func (c *Client) GetFilterWeights(name string) ([]*core.Weight, error) {
 			c.userID, familyID, ts,
 		)
 
-		req, err := http.NewRequest("GET", url, nil)
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 		if err != nil {
 			return nil, err
 		}
 
 		req.Header.Add("apptoken", c.appToken)
 
-		res, err := c.client.Do(req)
+		res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
 		if err != nil {
 			return nil, err
 		}
@@ -106,13 +108,13 @@ func (c *Client) GetFilterWeights(name string) ([]*core.Weight, error) {
 	return weights, nil
 }
 
-func (c *Client) GetFamilyID(name string) (int64, error) {
+func (c *Client) GetFamilyID(ctx context.Context, name string) (int64, error) {
 	if name == "" {
 		return -1, nil
 	}
 
 	if c.family == nil {
-		if err := c.GetFamilyMembers(); err != nil {
+		if err := c.GetFamilyMembers(ctx); err != nil {
 			return 0, err
 		}
 	}
@@ -124,9 +126,9 @@ func (c *Client) GetFamilyID(name string) (int64, error) {
 	return 0, errors.New("zepp: can't find family member: " + name)
 }
 
-func (c *Client) GetFamilyMembers() error {
-	req, err := http.NewRequest(
-		"POST", "https://api-mifit.zepp.com/huami.health.scale.familymember.get.json",
+func (c *Client) GetFamilyMembers(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(
+		ctx, "POST", "https://api-mifit.zepp.com/huami.health.scale.familymember.get.json",
 		strings.NewReader("fuid=all&userid="+c.userID),
 	)
 	if err != nil {
@@ -136,7 +138,7 @@ func (c *Client) GetFamilyMembers() error {
 	req.Header.Add("apptoken", c.appToken)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	res, err := c.client.Do(req)
+	res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
 	if err != nil {
 		return err
 	}
@@ -180,14 +182,14 @@ func (c *Client) GetFamilyMembers() error {
 	return nil
 }
 
-func (c *Client) AddWeights(weights []*core.Weight) error {
+func (c *Client) AddWeights(ctx context.Context, weights []*core.Weight) error {
 	if len(weights) == 0 {
 		return nil
 	}
 
 	var records []*Record
 	for _, weight := range weights {
-		familyID, err := c.GetFamilyID(weight.User)
+		familyID, err := c.GetFamilyID(ctx, weight.User)
 		if err != nil {
 			return err
 		}
@@ -230,8 +232,8 @@ func (c *Client) AddWeights(weights []*core.Weight) error {
 		return err
 	}
 
-	req, err := http.NewRequest(
-		"POST", "https://api-mifit.zepp.com/users/"+c.userID+"/members/-1/weightRecords", bytes.NewReader(body),
+	req, err := http.NewRequestWithContext(
+		ctx, "POST", "https://api-mifit.zepp.com/users/"+c.userID+"/members/-1/weightRecords", bytes.NewReader(body),
 	)
 	if err != nil {
 		return err
@@ -240,7 +242,7 @@ func (c *Client) AddWeights(weights []*core.Weight) error {
 	req.Header.Add("apptoken", c.appToken)
 	req.Header.Add("Content-Type", "application/json")
 
-	res, err := c.client.Do(req)
+	res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
 	if err != nil {
 		return err
 	}
@@ -253,8 +255,8 @@ func (c *Client) AddWeights(weights []*core.Weight) error {
 	return nil
 }
 
-func (c *Client) DeleteWeight(weight *core.Weight) error {
-	familyID, err := c.GetFamilyID(weight.User)
+func (c *Client) DeleteWeight(ctx context.Context, weight *core.Weight) error {
+	familyID, err := c.GetFamilyID(ctx, weight.User)
 	if err != nil {
 		return err
 	}
@@ -262,8 +264,8 @@ func (c *Client) DeleteWeight(weight *core.Weight) error {
 	data := fmt.Sprintf(`[{"ts":%d,"fuid":"%d"}]`, weight.Date.Unix(), familyID)
 
 	form := url.Values{"dt": {"1"}, "jsondata": {data}, "userid": {c.userID}}
-	req, err := http.NewRequest(
-		"POST", "https://api-mifit.zepp.com/huami.health.scale.delete.json", strings.NewReader(form.Encode()),
+	req, err := http.NewRequestWithContext(
+		ctx, "POST", "https://api-mifit.zepp.com/huami.health.scale.delete.json", strings.NewReader(form.Encode()),
 	)
 	if err != nil {
 		return err
@@ -272,7 +274,7 @@ func (c *Client) DeleteWeight(weight *core.Weight) error {
 	req.Header.Add("apptoken", c.appToken)
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	res, err := c.client.Do(req)
+	res, err := httpx.Do(ctx, c.client, req, httpx.DefaultPolicy)
 	if err != nil {
 		return err
 	}